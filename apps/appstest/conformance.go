@@ -0,0 +1,233 @@
+// Package appstest checks that implementations of apps.Apps and
+// apps.Registry behave as the clients do against the services, so that fakes
+// and alternative backends can't drift from them.
+package appstest
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vtex/go-clients/apps"
+	"github.com/vtex/go-clients/clients"
+)
+
+// AppsFactory returns an Apps where app is installed, with files
+type AppsFactory func(t *testing.T, app *apps.ActiveApp, files map[string][]byte) apps.Apps
+
+// RegistryFactory returns a Registry where app is published, with files
+type RegistryFactory func(t *testing.T, app *apps.PublishedApp, files map[string][]byte) apps.Registry
+
+var files = map[string][]byte{
+	"manifest.json":       []byte(`{"name":"app"}`),
+	"react/index.js":      []byte("export default {}"),
+	"react/components/a":  []byte("a"),
+	"node/service.json":   []byte("{}"),
+	"node/lib/handler.js": []byte("module.exports = {}"),
+}
+
+// RunAppsConformance runs the Apps conformance suite as subtests of t, each
+// on a new Apps from factory.
+func RunAppsConformance(t *testing.T, factory AppsFactory) {
+	newApps := func(t *testing.T) apps.Apps {
+		return factory(t, &apps.ActiveApp{Vendor: "vtex", Name: "app", Version: "1.2.3", Title: "App"}, files)
+	}
+
+	t.Run("GetApp", func(t *testing.T) {
+		a := newApps(t)
+		for _, name := range []string{"vtex.app", "vtex.app@1.2.3"} {
+			app, _, err := a.GetApp(name, "")
+			if err != nil {
+				t.Fatalf("GetApp %s: %v", name, err)
+			}
+			if app.Vendor != "vtex" || app.Name != "app" || app.Version != "1.2.3" || app.Title != "App" {
+				t.Errorf("GetApp %s: got %+v", name, app)
+			}
+		}
+		if _, _, err := a.GetApp("vtex.missing", ""); !errors.Is(err, clients.ErrNotFound) {
+			t.Errorf("GetApp of a missing app: got %v, want clients.ErrNotFound", err)
+		}
+	})
+
+	t.Run("Files", func(t *testing.T) {
+		a := newApps(t)
+		list, _, err := a.ListFiles("vtex.app", "")
+		if err != nil {
+			t.Fatalf("ListFiles: %v", err)
+		}
+		checkFileList(t, list)
+
+		res, _, err := a.GetFile("vtex.app", "", "react/index.js")
+		if err != nil {
+			t.Fatalf("GetFile: %v", err)
+		}
+		checkContent(t, "react/index.js", res)
+
+		if _, _, err := a.GetFile("vtex.app", "", "missing.js"); !errors.Is(err, clients.ErrNotFound) {
+			t.Errorf("GetFile of a missing file: got %v, want clients.ErrNotFound", err)
+		}
+		if _, _, err := a.ListFiles("vtex.missing", ""); !errors.Is(err, clients.ErrNotFound) {
+			t.Errorf("ListFiles of a missing app: got %v, want clients.ErrNotFound", err)
+		}
+	})
+
+	t.Run("GetBundle", func(t *testing.T) {
+		a := newApps(t)
+		bundle, _, err := a.GetBundle("vtex.app", "", "react")
+		if err != nil {
+			t.Fatalf("GetBundle: %v", err)
+		}
+		checkBundle(t, bundle, "react")
+	})
+
+	t.Run("GetDependencies", func(t *testing.T) {
+		a := newApps(t)
+		if _, _, err := a.GetDependencies(); err != nil {
+			t.Errorf("GetDependencies: %v", err)
+		}
+	})
+
+	t.Run("WithContext", func(t *testing.T) {
+		a := newApps(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, _, err := a.WithContext(ctx).GetApp("vtex.app", ""); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetApp with a canceled context: got %v, want context.Canceled", err)
+		}
+	})
+}
+
+// RunRegistryConformance runs the Registry conformance suite as subtests of
+// t, each on a new Registry from factory.
+func RunRegistryConformance(t *testing.T, factory RegistryFactory) {
+	newRegistry := func(t *testing.T) apps.Registry {
+		return factory(t, &apps.PublishedApp{Vendor: "vtex", Name: "app", Version: "1.2.3", Title: "App"}, files)
+	}
+
+	t.Run("GetApp", func(t *testing.T) {
+		r := newRegistry(t)
+		app, _, err := r.GetApp("vtex.app@1.2.3")
+		if err != nil {
+			t.Fatalf("GetApp: %v", err)
+		}
+		if app.Vendor != "vtex" || app.Name != "app" || app.Version != "1.2.3" || app.Title != "App" {
+			t.Errorf("GetApp: got %+v", app)
+		}
+		if _, _, err := r.GetApp("vtex.app@2.0.0"); !errors.Is(err, clients.ErrNotFound) {
+			t.Errorf("GetApp of a missing version: got %v, want clients.ErrNotFound", err)
+		}
+
+		var respErr clients.ResponseError
+		if _, _, err := r.GetApp("vtex.app"); err == nil || errors.As(err, &respErr) {
+			t.Errorf("GetApp of an id without version: got %v, want an error without request", err)
+		}
+	})
+
+	t.Run("Files", func(t *testing.T) {
+		r := newRegistry(t)
+		list, _, err := r.ListFiles("vtex.app@1.2.3")
+		if err != nil {
+			t.Fatalf("ListFiles: %v", err)
+		}
+		checkFileList(t, list)
+
+		res, _, err := r.GetFile("vtex.app@1.2.3", "react/index.js")
+		if err != nil {
+			t.Fatalf("GetFile: %v", err)
+		}
+		checkContent(t, "react/index.js", res)
+
+		if _, _, err := r.GetFile("vtex.app@1.2.3", "missing.js"); !errors.Is(err, clients.ErrNotFound) {
+			t.Errorf("GetFile of a missing file: got %v, want clients.ErrNotFound", err)
+		}
+	})
+
+	t.Run("GetBundle", func(t *testing.T) {
+		r := newRegistry(t)
+		bundle, _, err := r.GetBundle("vtex.app@1.2.3", "node")
+		if err != nil {
+			t.Fatalf("GetBundle: %v", err)
+		}
+		checkBundle(t, bundle, "node")
+	})
+
+	t.Run("WithContext", func(t *testing.T) {
+		r := newRegistry(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, _, err := r.WithContext(ctx).GetApp("vtex.app@1.2.3"); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetApp with a canceled context: got %v, want context.Canceled", err)
+		}
+	})
+}
+
+func checkFileList(t *testing.T, list *apps.FileList) {
+	t.Helper()
+	var got []string
+	for _, f := range list.Files {
+		got = append(got, f.Path)
+	}
+	sort.Strings(got)
+
+	var want []string
+	for path := range files {
+		want = append(want, path)
+	}
+	sort.Strings(want)
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ListFiles: got %v, want %v", got, want)
+	}
+}
+
+func checkContent(t *testing.T, path string, r io.Reader) {
+	t.Helper()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	if string(content) != string(files[path]) {
+		t.Errorf("%s: got %q, want %q", path, content, files[path])
+	}
+}
+
+// checkBundle checks that a bundle is the gzipped tarball of the files under
+// folder, with paths relative to it.
+func checkBundle(t *testing.T, bundle io.Reader, folder string) {
+	t.Helper()
+	gz, err := gzip.NewReader(bundle)
+	if err != nil {
+		t.Fatalf("GetBundle: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	got := map[string]bool{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("GetBundle: %v", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		got[header.Name] = true
+		checkContent(t, folder+"/"+header.Name, tr)
+	}
+
+	for path := range files {
+		if strings.HasPrefix(path, folder+"/") && !got[strings.TrimPrefix(path, folder+"/")] {
+			t.Errorf("GetBundle: missing %s", path)
+		}
+	}
+	if len(got) == 0 {
+		t.Error("GetBundle: empty bundle")
+	}
+}
@@ -0,0 +1,47 @@
+package appstest
+
+import (
+	"testing"
+
+	"github.com/vtex/go-clients/apps"
+	"github.com/vtex/go-clients/fakes"
+	"github.com/vtex/go-clients/standin"
+)
+
+func TestFakeConformance(t *testing.T) {
+	RunAppsConformance(t, func(t *testing.T, app *apps.ActiveApp, files map[string][]byte) apps.Apps {
+		a := fakes.NewApps()
+		a.Install(app, files)
+		return a
+	})
+	RunRegistryConformance(t, func(t *testing.T, app *apps.PublishedApp, files map[string][]byte) apps.Registry {
+		r := fakes.NewRegistry()
+		r.Publish(app, files)
+		return r
+	})
+}
+
+func TestClientConformance(t *testing.T) {
+	RunAppsConformance(t, func(t *testing.T, app *apps.ActiveApp, files map[string][]byte) apps.Apps {
+		s := standin.New()
+		t.Cleanup(s.Close)
+		s.InstallApp("account", "workspace", app, files)
+
+		a, err := apps.New(s.Config("account", "workspace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	})
+	RunRegistryConformance(t, func(t *testing.T, app *apps.PublishedApp, files map[string][]byte) apps.Registry {
+		s := standin.New()
+		t.Cleanup(s.Close)
+		s.PublishApp("account", app, files)
+
+		r, err := apps.NewRegistry(s.Config("account", "workspace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
@@ -1,13 +1,12 @@
 package apps
 
 import (
+	"context"
 	"fmt"
 	"io"
 
 	"github.com/vtex/go-clients/clients"
 	"gopkg.in/h2non/gentleman.v1"
-	"gopkg.in/h2non/gentleman.v1/context"
-	"gopkg.in/h2non/gentleman.v1/plugin"
 )
 
 // Apps is an interface for interacting with apps
@@ -17,6 +16,7 @@ type Apps interface {
 	GetFile(app, parentID, path string) (*gentleman.Response, string, error)
 	GetBundle(app, parentID, rootFolder string) (io.Reader, string, error)
 	GetDependencies() (map[string][]string, string, error)
+	WithContext(ctx context.Context) Apps
 }
 
 // Client is a struct that provides interaction with apps
@@ -30,6 +30,20 @@ func NewAppsClient(config *clients.Config) Apps {
 	return &AppsClient{cl}
 }
 
+// New creates an Apps client, or returns an error if config is invalid
+func New(config *clients.Config) (Apps, error) {
+	cl, err := clients.NewClient("apps", config, true)
+	if err != nil {
+		return nil, err
+	}
+	return &AppsClient{cl}, nil
+}
+
+// WithContext returns a view of the client whose requests are bound to ctx
+func (cl *AppsClient) WithContext(ctx context.Context) Apps {
+	return &AppsClient{clients.WithContext(cl.http, ctx)}
+}
+
 const (
 	pathToDependencies = "/dependencies"
 	pathToApp          = "/apps/%v"
@@ -113,14 +127,3 @@ func (cl *AppsClient) GetDependencies() (map[string][]string, string, error) {
 
 	return dependencies, res.Header.Get(clients.HeaderETag), err
 }
-
-func addParent(parentID string) plugin.Plugin {
-	return plugin.NewRequestPlugin(func(ctx *context.Context, h context.Handler) {
-		if parentID != "" {
-			query := ctx.Request.URL.Query()
-			query.Set("parent", parentID)
-			ctx.Request.URL.RawQuery = query.Encode()
-		}
-		h.Next(ctx)
-	})
-}
@@ -0,0 +1,18 @@
+package apps
+
+import (
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// addParent sets the parent query parameter of app requests, if any
+func addParent(parentID string) plugin.Plugin {
+	return plugin.NewRequestPlugin(func(ctx *context.Context, h context.Handler) {
+		if parentID != "" {
+			query := ctx.Request.URL.Query()
+			query.Set("parent", parentID)
+			ctx.Request.URL.RawQuery = query.Encode()
+		}
+		h.Next(ctx)
+	})
+}
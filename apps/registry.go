@@ -1,6 +1,7 @@
 package apps
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -15,6 +16,7 @@ type Registry interface {
 	ListFiles(id string) (*FileList, string, error)
 	GetFile(id, path string) (*gentleman.Response, string, error)
 	GetBundle(id, rootFolder string) (io.Reader, string, error)
+	WithContext(ctx context.Context) Registry
 }
 
 // Client is a struct that provides interaction with apps
@@ -24,15 +26,35 @@ type RegistryClient struct {
 
 // NewClient creates a new Registry client
 func NewRegistryClient(config *clients.Config) Registry {
-	if config.Workspace != "master" {
-		configCopy := *config
-		configCopy.Workspace = "master"
-		config = &configCopy
-	}
-	cl := clients.CreateClient("apps", config, true)
+	cl := clients.CreateClient("apps", masterConfig(config), true)
 	return &RegistryClient{cl}
 }
 
+// NewRegistry creates a Registry client, or returns an error if config is
+// invalid. The registry lives in the master workspace, whatever the
+// workspace of config.
+func NewRegistry(config *clients.Config) (Registry, error) {
+	cl, err := clients.NewClient("apps", masterConfig(config), true)
+	if err != nil {
+		return nil, err
+	}
+	return &RegistryClient{cl}, nil
+}
+
+func masterConfig(config *clients.Config) *clients.Config {
+	if config == nil || config.Workspace == "master" {
+		return config
+	}
+	configCopy := *config
+	configCopy.Workspace = "master"
+	return &configCopy
+}
+
+// WithContext returns a view of the client whose requests are bound to ctx
+func (cl *RegistryClient) WithContext(ctx context.Context) Registry {
+	return &RegistryClient{clients.WithContext(cl.http, ctx)}
+}
+
 const (
 	metadataPath    = "/registry/%v/%v"
 	fileListPath    = "/registry/%v/%v/files"
@@ -0,0 +1,227 @@
+// Package calltrace reads back the call trees accumulated in the X-Call-Trace
+// header of a request, to summarize and display where its time went.
+package calltrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vtex/go-clients/clients"
+)
+
+// Parse decodes the value of an X-Call-Trace header. An empty value holds no
+// calls, and null calls are skipped at any level.
+func Parse(value string) ([]*clients.CallTree, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var trees []*clients.CallTree
+	if err := json.Unmarshal([]byte(value), &trees); err != nil {
+		return nil, fmt.Errorf("Error unmarshaling call trace: %v", err)
+	}
+	return prune(trees), nil
+}
+
+// prune removes the null calls of trees and of their children
+func prune(trees []*clients.CallTree) []*clients.CallTree {
+	pruned := trees[:0]
+	for _, tree := range trees {
+		if tree != nil {
+			tree.Children = prune(tree.Children)
+			pruned = append(pruned, tree)
+		}
+	}
+	if len(pruned) == 0 {
+		return nil
+	}
+	return pruned
+}
+
+// FromHeader decodes the X-Call-Trace header of a request or response
+func FromHeader(h http.Header) ([]*clients.CallTree, error) {
+	return Parse(h.Get(clients.HeaderCallTrace))
+}
+
+// FromRequestContext decodes the calls traced so far in a RequestContext
+func FromRequestContext(reqCtx clients.RequestContext) ([]*clients.CallTree, error) {
+	return Parse(reqCtx.GetS(clients.HeaderCallTrace))
+}
+
+// Summary holds the totals of a set of call trees
+type Summary struct {
+	// Calls counts every call, nested ones included
+	Calls int
+	// TotalTime adds up the time of the top-level calls
+	TotalTime time.Duration
+	// CriticalPath starts at the slowest top-level call and descends into
+	// the slowest child of each call. Call trees carry no start times, so
+	// this assumes the calls at each level ran concurrently: when they ran
+	// one after the other, the time actually spent is closer to TotalTime.
+	CriticalPath []*clients.CallTree
+	CacheHits    int
+	// CacheHitRatio is CacheHits over Calls, or zero when there are none
+	CacheHitRatio float64
+	// Slowest lists the slowest calls at any level, slowest first
+	Slowest         []*clients.CallTree
+	CallsPerService map[string]int
+}
+
+// CriticalTime is the time of the first call of the critical path
+func (s *Summary) CriticalTime() time.Duration {
+	if len(s.CriticalPath) == 0 {
+		return 0
+	}
+	return duration(s.CriticalPath[0])
+}
+
+func (s *Summary) String() string {
+	services := make([]string, 0, len(s.CallsPerService))
+	for service := range s.CallsPerService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	perService := make([]string, len(services))
+	for i, service := range services {
+		perService[i] = fmt.Sprintf("%s=%d", service, s.CallsPerService[service])
+	}
+
+	return fmt.Sprintf("%d calls in %v (critical path %v), %.0f%% cache hits, per service: %s",
+		s.Calls, s.TotalTime, s.CriticalTime(), s.CacheHitRatio*100, strings.Join(perService, " "))
+}
+
+// Analyze computes the totals of trees, keeping the given number of slowest
+// calls. Null calls are ignored.
+func Analyze(trees []*clients.CallTree, slowest int) *Summary {
+	s := &Summary{CallsPerService: map[string]int{}}
+
+	var all []*clients.CallTree
+	walk(trees, 0, func(tree *clients.CallTree, depth int) {
+		all = append(all, tree)
+		s.CallsPerService[Service(tree)]++
+		if tree.Cache == "hit" {
+			s.CacheHits++
+		}
+	})
+
+	s.Calls = len(all)
+	if s.Calls > 0 {
+		s.CacheHitRatio = float64(s.CacheHits) / float64(s.Calls)
+	}
+
+	for _, tree := range trees {
+		if tree != nil {
+			s.TotalTime += duration(tree)
+		}
+	}
+
+	for level := trees; len(level) > 0; {
+		var slowest *clients.CallTree
+		for _, tree := range level {
+			if tree != nil && (slowest == nil || tree.Time > slowest.Time) {
+				slowest = tree
+			}
+		}
+		if slowest == nil {
+			break
+		}
+		s.CriticalPath = append(s.CriticalPath, slowest)
+		level = slowest.Children
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time > all[j].Time
+	})
+	if slowest < 0 {
+		slowest = 0
+	} else if slowest > len(all) {
+		slowest = len(all)
+	}
+	s.Slowest = all[:slowest]
+
+	return s
+}
+
+// Service returns the service a call was made to. Calls traced by older
+// clients only tell it through the host they were sent to.
+func Service(tree *clients.CallTree) string {
+	if tree.Service != "" {
+		return tree.Service
+	}
+
+	parts := strings.SplitN(tree.Call, " ", 2)
+	u, err := url.Parse(parts[len(parts)-1])
+	if err != nil || u.Hostname() == "" {
+		return "unknown"
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	return strings.SplitN(host, ".", 2)[0]
+}
+
+const waterfallWidth = 40
+
+// Waterfall writes one line per call, nested calls indented under their
+// caller, with a bar scaled to the slowest top-level call. Traces only record
+// durations, so all bars start at the left.
+func Waterfall(w io.Writer, trees []*clients.CallTree) error {
+	var longest int64
+	var callWidth int
+	walk(trees, 0, func(tree *clients.CallTree, depth int) {
+		if depth == 0 && tree.Time > longest {
+			longest = tree.Time
+		}
+		if width := 2*depth + len(tree.Call); width > callWidth {
+			callWidth = width
+		}
+	})
+
+	var err error
+	walk(trees, 0, func(tree *clients.CallTree, depth int) {
+		if err != nil {
+			return
+		}
+
+		bar := 0
+		if longest > 0 {
+			bar = int(tree.Time * waterfallWidth / longest)
+		}
+		if bar == 0 && tree.Time > 0 {
+			bar = 1
+		}
+		if bar > waterfallWidth {
+			bar = waterfallWidth
+		}
+
+		call := strings.Repeat("  ", depth) + tree.Call
+		_, err = fmt.Fprintf(w, "%-*s %3d %-4s %6dms |%s%s|\n",
+			callWidth, call, tree.Status, tree.Cache, tree.Time,
+			strings.Repeat("#", bar), strings.Repeat(" ", waterfallWidth-bar))
+	})
+	return err
+}
+
+func walk(trees []*clients.CallTree, depth int, fn func(tree *clients.CallTree, depth int)) {
+	for _, tree := range trees {
+		if tree == nil {
+			continue
+		}
+		fn(tree, depth)
+		walk(tree.Children, depth+1, fn)
+	}
+}
+
+func duration(tree *clients.CallTree) time.Duration {
+	return time.Duration(tree.Time) * time.Millisecond
+}
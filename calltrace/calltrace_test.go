@@ -0,0 +1,97 @@
+package calltrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vtex/go-clients/clients"
+)
+
+const trace = `[
+	{"call": "GET http://vbase.aws-us-east-1.vtex.io/a/w/buckets/b/files/f", "status": 200, "cache": "miss", "time": 120, "children": [
+		{"call": "GET http://10.0.0.1/x", "status": 200, "cache": "hit", "time": 30},
+		{"call": "GET http://10.0.0.2/y", "status": 200, "cache": "miss", "time": 80}
+	]},
+	{"call": "PUT http://127.0.0.1:80/a", "service": "apps", "status": 204, "cache": "miss", "time": 40}
+]`
+
+func TestAnalyze(t *testing.T) {
+	trees, err := Parse(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Analyze(trees, 2)
+	if s.Calls != 4 || s.CacheHits != 1 || s.CacheHitRatio != 0.25 {
+		t.Errorf("got %d calls with %d hits (%v), want 4 with 1 (0.25)", s.Calls, s.CacheHits, s.CacheHitRatio)
+	}
+	if s.TotalTime != 160*time.Millisecond || s.CriticalTime() != 120*time.Millisecond {
+		t.Errorf("got total %v and critical %v, want 160ms and 120ms", s.TotalTime, s.CriticalTime())
+	}
+	if len(s.CriticalPath) != 2 || s.CriticalPath[1].Time != 80 {
+		t.Errorf("got critical path %v, want the first call and its slowest child", s.CriticalPath)
+	}
+	if len(s.Slowest) != 2 || s.Slowest[0].Time != 120 || s.Slowest[1].Time != 80 {
+		t.Errorf("got slowest %v, want the calls of 120ms and 80ms", s.Slowest)
+	}
+
+	want := map[string]int{"vbase": 1, "10.0.0.1": 1, "10.0.0.2": 1, "apps": 1}
+	for service, n := range want {
+		if s.CallsPerService[service] != n {
+			t.Errorf("got %d calls to %s, want %d", s.CallsPerService[service], service, n)
+		}
+	}
+}
+
+func TestNullCalls(t *testing.T) {
+	for _, value := range []string{
+		`[null]`,
+		`[null, {"call": "GET http://vbase.r.vtex.io/a", "status": 200, "cache": "miss", "time": 10, "children": [null]}]`,
+	} {
+		trees, err := Parse(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tree := range trees {
+			if tree == nil || len(tree.Children) > 0 {
+				t.Errorf("Parse(%q) kept a null call", value)
+			}
+		}
+
+		Analyze(trees, 10)
+		if err := Waterfall(&bytes.Buffer{}, trees); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Trees built by hand may hold null calls too
+	trees := []*clients.CallTree{nil, {Call: "GET http://vbase.r.vtex.io/a", Time: 10, Children: []*clients.CallTree{nil}}}
+	if s := Analyze(trees, 10); s.Calls != 1 || len(s.CriticalPath) != 1 {
+		t.Errorf("got %d calls and critical path %v, want the only call", s.Calls, s.CriticalPath)
+	}
+	if s := Analyze([]*clients.CallTree{nil}, 10); s.Calls != 0 || len(s.CriticalPath) != 0 {
+		t.Errorf("got %d calls and critical path %v, want none", s.Calls, s.CriticalPath)
+	}
+}
+
+func TestWaterfall(t *testing.T) {
+	trees, _ := Parse(trace)
+
+	var buf bytes.Buffer
+	if err := Waterfall(&buf, trees); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want one per call:\n%s", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[1], "  GET http://10.0.0.1/x") {
+		t.Errorf("nested call is not indented: %q", lines[1])
+	}
+	if bar := strings.Count(lines[0], "#"); bar != waterfallWidth {
+		t.Errorf("slowest call has a bar of %d, want %d", bar, waterfallWidth)
+	}
+}
@@ -0,0 +1,171 @@
+// Package cassette records the HTTP interactions of the clients into files
+// and replays them, so that code built on the clients can be tested
+// deterministically and without network access. Both the Recorder and the
+// Replayer are meant to be set as clients.Config.Transport.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Redacted replaces the values of RedactedHeaders in cassettes
+const Redacted = "REDACTED"
+
+// RedactedHeaders are never written to cassettes as they hold credentials
+var RedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"X-Vtex-Credential",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// Cassette is the content of a cassette file
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response it got
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request. Bodies are kept as text so that changes to
+// cassettes can be reviewed.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is the recorded response to a Request
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Load reads a cassette file
+func Load(path string) (*Cassette, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading cassette: %v", err)
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(buf, &c); err != nil {
+		return nil, fmt.Errorf("Error unmarshaling cassette %s: %v", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes c to a cassette file, creating its directory if needed
+func (c *Cassette) Save(path string) error {
+	buf, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error marshaling cassette: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Error writing cassette: %v", err)
+	}
+	if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+		return fmt.Errorf("Error writing cassette: %v", err)
+	}
+	return nil
+}
+
+// Recorder sends requests through a transport and records them in a
+// cassette, which is written to its file by Stop.
+type Recorder struct {
+	sync.Mutex
+	path      string
+	transport http.RoundTripper
+	cassette  *Cassette
+}
+
+// NewRecorder creates a Recorder writing to path. A nil transport stands for
+// http.DefaultTransport.
+func NewRecorder(path string, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{
+		path:      path,
+		transport: transport,
+		cassette:  &Cassette{Interactions: []*Interaction{}},
+	}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: redact(req.Header),
+			Body:   string(reqBody),
+		},
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     redact(res.Header),
+			Body:       string(resBody),
+		},
+	})
+	return res, nil
+}
+
+// Stop writes the interactions recorded so far to the cassette file. It is
+// meant to be deferred, or registered with testing.T.Cleanup, once the
+// Recorder is created.
+func (r *Recorder) Stop() error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.cassette.Save(r.path)
+}
+
+func redact(h http.Header) http.Header {
+	redacted := h.Clone()
+	for _, name := range RedactedHeaders {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, Redacted)
+		}
+	}
+	return redacted
+}
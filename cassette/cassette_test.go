@@ -0,0 +1,127 @@
+package cassette
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/vbase"
+)
+
+func newConfig(endpoint string, transport http.RoundTripper) *clients.Config {
+	return &clients.Config{
+		Account:        "account",
+		Workspace:      "master",
+		Endpoint:       endpoint,
+		AuthToken:      "secret-token",
+		RequestContext: clients.NewRequestContext(nil),
+		Transport:      transport,
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	gets := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret-cookie")
+		if r.Method == http.MethodGet {
+			gets++
+			w.Write([]byte("version " + strconv.Itoa(gets)))
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "vbase.json")
+	recorder := NewRecorder(path, nil)
+	v, err := vbase.New(newConfig(srv.URL, recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.SaveFile("bucket", "file.txt", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	v.GetFile("bucket", "file.txt")
+	v.GetFile("bucket", "file.txt")
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("cassette was written before Stop: %v", err)
+	}
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "secret-cookie"} {
+		if strings.Contains(string(buf), secret) {
+			t.Errorf("cassette holds %s", secret)
+		}
+	}
+	if !strings.Contains(string(buf), `"body": "hello"`) {
+		t.Errorf("cassette doesn't hold the request body as text:\n%s", buf)
+	}
+
+	// The host of the recorded requests doesn't matter
+	replayer, err := NewReplayer(path, DefaultMatch|MatchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ = vbase.New(newConfig("http://replayed.invalid", replayer))
+	if _, err := v.SaveFile("bucket", "file.txt", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"version 1", "version 2", "version 2"} {
+		res, _, err := v.GetFile("bucket", "file.txt")
+		if err != nil || res.String() != want {
+			t.Errorf("replayed %v, %v, want %q", res, err, want)
+		}
+	}
+}
+
+func TestReplayNotFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vbase.json")
+	c := &Cassette{Interactions: []*Interaction{{
+		Request:  Request{Method: http.MethodPut, URL: "http://vbase/account/master/buckets/bucket/files/file.txt", Body: "hello"},
+		Response: Response{StatusCode: http.StatusOK},
+	}}}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := NewReplayer(path, DefaultMatch|MatchBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := vbase.New(newConfig("http://replayed.invalid", replayer))
+
+	for name, send := range map[string]func() error{
+		"another path":   func() error { _, err := v.SaveFile("bucket", "other.txt", strings.NewReader("hello")); return err },
+		"another method": func() error { return v.DeleteFile("bucket", "file.txt") },
+		"another body":   func() error { _, err := v.SaveFile("bucket", "file.txt", strings.NewReader("bye")); return err },
+	} {
+		var notFound *NotFoundError
+		if err := send(); !errors.As(err, &notFound) || notFound.Cassette != path {
+			t.Errorf("request with %s got %v, want a NotFoundError", name, err)
+		}
+	}
+	if _, err := v.SaveFile("bucket", "file.txt", strings.NewReader("hello")); err != nil {
+		t.Errorf("matching request failed: %v", err)
+	}
+}
+
+func TestMatchString(t *testing.T) {
+	if s := DefaultMatch.String(); s != "method, path, query" {
+		t.Errorf("DefaultMatch is %q", s)
+	}
+	if s := Match(0).String(); s != "nothing" {
+		t.Errorf("Match(0) is %q", s)
+	}
+}
@@ -0,0 +1,148 @@
+package cassette
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Match selects what a request must have in common with a recorded one to be
+// served its response. The host is never compared, so that cassettes can be
+// replayed against any endpoint.
+type Match int
+
+const (
+	MatchMethod Match = 1 << iota
+	MatchPath
+	// MatchQuery compares the query parameters, in any order
+	MatchQuery
+	MatchBody
+
+	DefaultMatch = MatchMethod | MatchPath | MatchQuery
+)
+
+func (m Match) String() string {
+	var parts []string
+	for _, f := range []struct {
+		match Match
+		name  string
+	}{{MatchMethod, "method"}, {MatchPath, "path"}, {MatchQuery, "query"}, {MatchBody, "body"}} {
+		if m&f.match != 0 {
+			parts = append(parts, f.name)
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// NotFoundError is returned for requests that match no interaction of the
+// cassette being replayed.
+type NotFoundError struct {
+	Cassette string
+	Method   string
+	URL      string
+	Match    Match
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("cassette %s has no interaction for %s %s (matching %v)",
+		err.Cassette, err.Method, err.URL, err.Match)
+}
+
+// Replayer serves the responses of a cassette without sending any request.
+// Matching interactions are served in the order they were recorded, so a
+// sequence of identical requests gets each of its recorded responses; once
+// they are all used, the last one is served again.
+type Replayer struct {
+	sync.Mutex
+	path     string
+	match    Match
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayer loads the cassette at path to replay it
+func NewReplayer(path string, match Match) (*Replayer, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{
+		path:     path,
+		match:    match,
+		cassette: c,
+		used:     make([]bool, len(c.Interactions)),
+	}, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	last := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !r.matches(&interaction.Request, req, body) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return response(&interaction.Response, req), nil
+		}
+		last = i
+	}
+
+	if last < 0 {
+		return nil, &NotFoundError{r.path, req.Method, req.URL.String(), r.match}
+	}
+	return response(&r.cassette.Interactions[last].Response, req), nil
+}
+
+func (r *Replayer) matches(recorded *Request, req *http.Request, body []byte) bool {
+	if r.match&MatchMethod != 0 && recorded.Method != req.Method {
+		return false
+	}
+
+	if r.match&(MatchPath|MatchQuery) != 0 {
+		u, err := url.Parse(recorded.URL)
+		if err != nil {
+			return false
+		}
+		if r.match&MatchPath != 0 && u.Path != req.URL.Path {
+			return false
+		}
+		if r.match&MatchQuery != 0 && !reflect.DeepEqual(u.Query(), req.URL.Query()) {
+			return false
+		}
+	}
+
+	return r.match&MatchBody == 0 || recorded.Body == string(body)
+}
+
+func response(recorded *Response, req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          ioutil.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}
+}
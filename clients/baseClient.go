@@ -1,15 +1,16 @@
 package clients
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
-
+	"sync"
 	"time"
 
-	"github.com/Sirupsen/logrus"
 	"gopkg.in/h2non/gentleman.v1"
 	"gopkg.in/h2non/gentleman.v1/context"
 	"gopkg.in/h2non/gentleman.v1/plugin"
@@ -21,6 +22,8 @@ import (
 
 const HeaderETag = "ETag"
 
+const defaultTimeout = 5 * time.Second
+
 type Config struct {
 	Account        string
 	Workspace      string
@@ -32,8 +35,64 @@ type Config struct {
 	RequestContext RequestContext
 	Timeout        time.Duration
 	Transport      http.RoundTripper
+	// TokenSource provides the credential of requests when AuthToken is
+	// empty, taking precedence over AuthFunc. Sources that mint tokens should
+	// be wrapped with NewCachedTokenSource.
+	TokenSource TokenSource
+	// Scheme of the service URLs, "http" by default. Endpoints that include
+	// a scheme keep theirs.
+	Scheme string
+	// EndpointTemplate builds the endpoint of each service, replacing the
+	// {service}, {region} and {account} placeholders. It defaults to
+	// "{service}.{region}.vtex.io" and is overridden by Endpoint.
+	EndpointTemplate string
+	// Endpoints overrides the endpoint of specific services, by service name
+	// as in "vbase" or "kube-router".
+	Endpoints map[string]string
+	// TLSConfig sets the CA pool, client certificates and server name used
+	// to reach https endpoints. Clients with the same TLSConfig share a
+	// transport and its connections, so it must not be changed once used. It
+	// is ignored when Transport is set.
+	TLSConfig *tls.Config
+	// Regions lists the regions to fail over to, in order of preference. When
+	// set, its first region takes the place of Region. Idempotent requests
+	// that fail in a region are sent to the next healthy one, as long as the
+	// service endpoint comes from EndpointTemplate.
+	Regions []string
+	// RateLimits bounds the requests sent to each service, by service name as
+	// in "vbase" or "kube-router". A limit is shared by every client of the
+	// process that talks to the same endpoint with the same limit, and
+	// applies to each region a request fails over to on its own.
+	RateLimits map[string]*RateLimit
+	// RetryPolicy enables retries of failed requests. The whole call,
+	// retries and their waits included, is still bounded by Timeout.
+	RetryPolicy *RetryPolicy
+	// CircuitBreaker enables a circuit breaker per service endpoint, failing
+	// requests fast with ErrCircuitOpen while the service is degraded. Each
+	// region a request fails over to has its own.
+	CircuitBreaker *BreakerPolicy
+	// Cache enables caching of the GET responses of apps, the registry and
+	// vbase, honoring their Cache-Control max-age and Vary headers and
+	// revalidating them with their ETag. Writes evict the response of their
+	// URL.
+	Cache Cache
+	// Logger receives a debug event for every request. Logging is disabled
+	// when it is nil.
+	Logger Logger
+	// Observer is notified of every request, e.g. to collect metrics with a
+	// PrometheusCollector.
+	Observer Observer
+	// SpanExporter receives a span for every attempt of a request sent to
+	// the network. W3C trace context is propagated whether it is set or not.
+	SpanExporter SpanExporter
+
+	// factory is set on configs returned by Factory.Config
+	factory *Factory
 }
 
+// CreateClient creates a client for a service, panicking if config or its
+// RequestContext is nil. Unlike NewClient it doesn't validate the rest of the
+// config, so that existing callers keep working.
 func CreateClient(service string, config *Config, workspaceBound bool) *gentleman.Client {
 	if config == nil {
 		panic("config cannot be <nil>")
@@ -43,27 +102,102 @@ func CreateClient(service string, config *Config, workspaceBound bool) *gentlema
 		panic("config.RequestContext cannot be <nil>")
 	}
 
-	if config.Timeout <= 0 {
-		config.Timeout = 5 * time.Second
+	return newClient(service, config, workspaceBound)
+}
+
+// NewClient creates a client for a service, or returns an error if config
+// is invalid for it. The config is copied, so the caller may change it
+// afterwards without affecting the client.
+func NewClient(service string, config *Config, workspaceBound bool) (*gentleman.Client, error) {
+	if err := config.validateFor(service, workspaceBound); err != nil {
+		return nil, fmt.Errorf("Error creating %s client: %w", service, err)
+	}
+	return newClient(service, config, workspaceBound), nil
+}
+
+func newClient(service string, config *Config, workspaceBound bool) *gentleman.Client {
+	configCopy := *config
+	config = &configCopy
+
+	if config.factory != nil {
+		return scopedClient(config.factory.sharedClient(service, config, workspaceBound), service, config)
+	}
+
+	return scopedClient(sharedClient(service, config, workspaceBound), service, config)
+}
+
+// sharedClient creates a client with the plugins that don't depend on the
+// request being served, so that it can be shared by a Factory.
+func sharedClient(service string, config *Config, workspaceBound bool) *gentleman.Client {
+	requestTimeout := config.Timeout
+	if requestTimeout <= 0 {
+		requestTimeout = defaultTimeout
 	}
 
-	cl := gentleman.New().
-		Use(timeout.Request(config.Timeout)).
+	cl := newGentlemanClient().
+		Use(timeout.Request(requestTimeout)).
 		Use(headers.Set("User-Agent", config.UserAgent)).
-		Use(responseErrors()).
-		Use(recordHeaders(config.RequestContext)).
-		Use(traceRequest(config.RequestContext))
+		Use(responseErrors(service)).
+		Use(wrapErrors(service)).
+		Use(logRequests(service, loggerOf(config)))
+
+	if config.Observer != nil {
+		cl = cl.Use(observeRequests(service, config.Observer))
+	}
 
 	if url := endpoint(service, config); url != "" {
 		cl = cl.BaseURL(url)
 	}
 
+	if config.CircuitBreaker != nil {
+		cl = cl.Use(breakRequests(config.CircuitBreaker.withDefaults()))
+	}
+
 	if path := basePath(config, workspaceBound); path != "" {
 		cl = cl.Path(path)
 	}
 
+	if config.Transport != nil {
+		cl = cl.Use(transport.Set(config.Transport))
+	} else if config.TLSConfig != nil {
+		cl = cl.Use(transport.Set(tlsTransportFor(config.TLSConfig)))
+	}
+
+	// Registered right after the transport so that every attempt, retries
+	// and failovers included, is traced and waits for its turn
+	cl = cl.Use(traceAttempts())
+	if limit, ok := config.RateLimits[service]; ok && limit != nil {
+		cl = cl.Use(limitRequests(limit))
+	}
+
+	if endpoints := regionEndpoints(service, config); len(endpoints) > 0 {
+		cl = cl.Use(failoverRequests(endpoints))
+	}
+
+	if config.RetryPolicy != nil {
+		cl = cl.Use(retryRequests(config.RetryPolicy.withDefaults()))
+	}
+
+	// Registered after retries so that cache hits never wait on them
+	if config.Cache != nil && cachedServices[service] {
+		cl = cl.Use(cacheResponses(config.Cache))
+	}
+
+	return cl
+}
+
+// scopedClient creates a child of a shared client with the plugins bound to
+// the request being served: its credential and RequestContext.
+func scopedClient(parent *gentleman.Client, service string, config *Config) *gentleman.Client {
+	cl := newGentlemanClient().UseParent(parent).
+		Use(recordHeaders(config.RequestContext)).
+		Use(traceRequest(service, configuredRegion(config), config.RequestContext, loggerOf(config))).
+		Use(propagateTrace(service, config.RequestContext, config.SpanExporter))
+
 	if config.AuthToken != "" {
 		cl = cl.Use(auth.Bearer(config.AuthToken))
+	} else if config.TokenSource != nil {
+		cl = cl.Use(authenticate(config.TokenSource))
 	} else if config.AuthFunc != nil {
 		cl = cl.UseRequest(func(ctx *context.Context, h context.Handler) {
 			ctx.Request.Header.Set("Authorization", "Bearer "+config.AuthFunc())
@@ -71,14 +205,17 @@ func CreateClient(service string, config *Config, workspaceBound bool) *gentlema
 		})
 	}
 
-	if config.Transport != nil {
-		cl = cl.Use(transport.Set(config.Transport))
-	}
-
 	return cl
 }
 
-func responseErrors() plugin.Plugin {
+func loggerOf(config *Config) Logger {
+	if config.Logger == nil {
+		return nopLogger{}
+	}
+	return config.Logger
+}
+
+func responseErrors(service string) plugin.Plugin {
 	return plugin.NewResponsePlugin(func(c *context.Context, h context.Handler) {
 		if 200 <= c.Response.StatusCode && c.Response.StatusCode < 400 {
 			h.Next(c)
@@ -97,9 +234,11 @@ func responseErrors() plugin.Plugin {
 
 		h.Error(c, ResponseError{
 			Response:   c.Response,
+			Service:    service,
 			StatusCode: c.Response.StatusCode,
 			Code:       descr.Code,
 			Message:    descr.Message,
+			Body:       buf,
 		})
 	})
 }
@@ -111,7 +250,9 @@ func recordHeaders(reqCtx RequestContext) plugin.Plugin {
 	})
 }
 
-func traceRequest(reqCtx RequestContext) plugin.Plugin {
+// traceRequest records a CallTree of each request, whose region is the one
+// the request failed over to, if any, or else the configured one.
+func traceRequest(service, region string, reqCtx RequestContext, logger Logger) plugin.Plugin {
 	const startTime = "startTime"
 
 	p := plugin.New()
@@ -122,7 +263,11 @@ func traceRequest(reqCtx RequestContext) plugin.Plugin {
 			h.Next(c)
 		})
 		p.SetHandler("response", func(c *context.Context, h context.Handler) {
-			tree := newCallTree(c.Request, c.Response, c.Get(startTime).(time.Time))
+			served := servedRegion(c)
+			if served == "" {
+				served = region
+			}
+			tree := newCallTree(service, served, c.Request, c.Response, c.Get(startTime).(time.Time), logger)
 			reqCtx.UpdateS(traceHeader, func(current string) string {
 				var traces []*CallTree
 				if err := json.Unmarshal([]byte(current), &traces); err != nil || current == "" {
@@ -141,17 +286,19 @@ func traceRequest(reqCtx RequestContext) plugin.Plugin {
 
 type CallTree struct {
 	Call     string      `json:"call"`
+	Service  string      `json:"service,omitempty"`
+	Region   string      `json:"region,omitempty"`
 	Status   int         `json:"status"`
 	Cache    string      `json:"cache"`
 	Time     int64       `json:"time"`
 	Children []*CallTree `json:"children,omitempty"`
 }
 
-func newCallTree(req *http.Request, res *http.Response, start time.Time) *CallTree {
+func newCallTree(service, region string, req *http.Request, res *http.Response, start time.Time, logger Logger) *CallTree {
 	resh := res.Header.Get(traceHeader)
 	var children []*CallTree
 	if err := json.Unmarshal([]byte(resh), &children); err != nil && resh != "" {
-		logrus.WithError(err).Error("Failed to unmarshal call trace")
+		logger.Error("Failed to unmarshal call trace", Fields{"error": err.Error()})
 	}
 
 	cache := "miss"
@@ -161,6 +308,8 @@ func newCallTree(req *http.Request, res *http.Response, start time.Time) *CallTr
 
 	return &CallTree{
 		Call:     req.Method + " " + req.URL.String(),
+		Service:  service,
+		Region:   region,
 		Time:     time.Now().Sub(start).Nanoseconds() / int64(time.Millisecond),
 		Status:   res.StatusCode,
 		Cache:    cache,
@@ -168,16 +317,89 @@ func newCallTree(req *http.Request, res *http.Response, start time.Time) *CallTr
 	}
 }
 
+const defaultEndpointTemplate = "{service}.{region}.vtex.io"
+
 func endpoint(service string, config *Config) string {
-	if config.Endpoint != "" {
-		return "http://" + strings.TrimRight(config.Endpoint, "/")
+	if override, ok := config.Endpoints[service]; ok && override != "" {
+		return withScheme(override, config)
+	} else if config.Endpoint != "" {
+		return withScheme(config.Endpoint, config)
 	} else if service != "" {
-		return fmt.Sprintf("http://%s.%s.vtex.io", service, config.Region)
+		return templateEndpoint(service, configuredRegion(config), config)
 	} else {
 		return ""
 	}
 }
 
+// dialedEndpoint returns the endpoint a request is sent to, which keys the
+// rate limiters and circuit breakers
+func dialedEndpoint(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// configuredRegion returns the preferred region of config
+func configuredRegion(config *Config) string {
+	if len(config.Regions) > 0 {
+		return config.Regions[0]
+	}
+	return config.Region
+}
+
+func templateEndpoint(service, region string, config *Config) string {
+	template := config.EndpointTemplate
+	if template == "" {
+		template = defaultEndpointTemplate
+	}
+	return withScheme(strings.NewReplacer(
+		"{service}", service,
+		"{region}", region,
+		"{account}", config.Account,
+	).Replace(template), config)
+}
+
+func withScheme(endpoint string, config *Config) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+
+	scheme := config.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + endpoint
+}
+
+// newTransport creates a transport with the TLS settings of config
+func newTransport(config *Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if config.TLSConfig != nil {
+		t.TLSClientConfig = config.TLSConfig.Clone()
+	}
+	return t
+}
+
+// tlsTransports holds a transport per TLS config, so that the clients created
+// for each request reuse the connections of the ones before
+var tlsTransports = struct {
+	sync.Mutex
+	m map[*tls.Config]*http.Transport
+}{m: map[*tls.Config]*http.Transport{}}
+
+// tlsTransportFor returns the transport shared by the clients with a TLS
+// config. Changes to the TLS config after its first use are ignored.
+func tlsTransportFor(tlsConfig *tls.Config) *http.Transport {
+	tlsTransports.Lock()
+	defer tlsTransports.Unlock()
+
+	t, ok := tlsTransports.m[tlsConfig]
+	if !ok {
+		t = newTransport(&Config{TLSConfig: tlsConfig})
+		tlsTransports.m[tlsConfig] = t
+	}
+	return t
+}
+
 func basePath(config *Config, workspaceBound bool) string {
 	if workspaceBound {
 		return "/" + config.Account + "/" + config.Workspace
@@ -0,0 +1,271 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// ErrCircuitOpen is returned, without sending the request, while the circuit
+// breaker of the target endpoint is open. Clients failing over between
+// regions send the request to the next region instead.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerPolicy configures the circuit breaker kept for each endpoint dialed.
+// Zero fields take the values of DefaultBreakerPolicy.
+type BreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failed attempts that
+	// opens the circuit. Failures are transport errors and 5xx or 429
+	// responses; errors raised before the request reaches the network, such
+	// as the caller's cancellation or a failing TokenSource, are not counted.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing again
+	OpenTimeout time.Duration
+	// HalfOpenProbes is how many requests are let through at once while
+	// probing, and how many must succeed to close the circuit again
+	HalfOpenProbes int
+}
+
+// DefaultBreakerPolicy opens the circuit after five consecutive failures and
+// probes the endpoint again ten seconds later.
+func DefaultBreakerPolicy() *BreakerPolicy {
+	return &BreakerPolicy{
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+		HalfOpenProbes:   1,
+	}
+}
+
+func (p *BreakerPolicy) withDefaults() *BreakerPolicy {
+	def := DefaultBreakerPolicy()
+	policy := *p
+
+	if policy.FailureThreshold <= 0 {
+		policy.FailureThreshold = def.FailureThreshold
+	}
+	if policy.OpenTimeout <= 0 {
+		policy.OpenTimeout = def.OpenTimeout
+	}
+	if policy.HalfOpenProbes <= 0 {
+		policy.HalfOpenProbes = def.HalfOpenProbes
+	}
+
+	return &policy
+}
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every request with ErrCircuitOpen
+	BreakerOpen
+	// BreakerHalfOpen lets a few probe requests through
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breakers are shared by every client talking to the same endpoint, so that
+// request-scoped clients see the same state. The policy of the first request
+// sent to an endpoint is the one in effect.
+var breakers = struct {
+	sync.Mutex
+	m map[string]*breaker
+}{m: map[string]*breaker{}}
+
+func breakerFor(endpoint string, policy *BreakerPolicy) *breaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+
+	b, ok := breakers.m[endpoint]
+	if !ok {
+		b = &breaker{policy: policy}
+		breakers.m[endpoint] = b
+	}
+	return b
+}
+
+// CircuitState returns the state of the circuit breaker for an endpoint, as
+// in "http://vbase.aws-us-east-1.vtex.io". Endpoints without a breaker are
+// reported as closed.
+func CircuitState(endpoint string) BreakerState {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = dialedEndpoint(u)
+	}
+
+	breakers.Lock()
+	b, ok := breakers.m[endpoint]
+	breakers.Unlock()
+
+	if !ok {
+		return BreakerClosed
+	}
+	return b.currentState()
+}
+
+type breaker struct {
+	sync.Mutex
+	policy    *BreakerPolicy
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+	// round counts the times the breaker went half-open, so that probes of
+	// a previous round are told apart
+	round int
+}
+
+// ticket is handed to each allowed request: the half-open round it probes,
+// or zero if it was admitted while the circuit was closed.
+type ticket int
+
+func (b *breaker) currentState() BreakerState {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.policy.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a request may be sent. Every allowed request must be
+// followed by a call to either record or release with its ticket.
+func (b *breaker) allow() (ticket, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.policy.OpenTimeout {
+			return 0, false
+		}
+		b.state = BreakerHalfOpen
+		b.probes = 0
+		b.successes = 0
+		b.round++
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.policy.HalfOpenProbes {
+			return 0, false
+		}
+		b.probes++
+		return ticket(b.round), true
+	}
+	return 0, true
+}
+
+// probing reports whether t is a probe of the current half-open round
+func (b *breaker) probing(t ticket) bool {
+	return b.state == BreakerHalfOpen && t != 0 && int(t) == b.round
+}
+
+// record accounts for the outcome of an allowed request. Outcomes only count
+// in the state the request was admitted in: requests admitted while closed
+// that finish once the circuit opened, or probes of a round that is over,
+// are ignored.
+func (b *breaker) record(t ticket, success bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	switch {
+	case b.state == BreakerClosed && t == 0:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.policy.FailureThreshold {
+			b.trip()
+		}
+	case b.probing(t):
+		b.probes--
+		if !success {
+			b.trip()
+			return
+		}
+		b.successes++
+		if b.successes >= b.policy.HalfOpenProbes {
+			b.state = BreakerClosed
+			b.failures = 0
+		}
+	}
+}
+
+// release gives back an allowed request whose outcome says nothing about the
+// endpoint's health, such as one cancelled by the caller.
+func (b *breaker) release(t ticket) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.probing(t) {
+		b.probes--
+	}
+}
+
+func (b *breaker) trip() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	b.failures = 0
+}
+
+// unhealthyStatus reports whether a response status tells that the endpoint
+// is failing or overloaded
+func unhealthyStatus(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// breakRequests wraps the transport of each request in a breakerTransport
+func breakRequests(policy *BreakerPolicy) plugin.Plugin {
+	return plugin.NewPhasePlugin("before dial", func(c *context.Context, h context.Handler) {
+		c.Client.Transport = &breakerTransport{next: c.Client.Transport, policy: policy}
+		h.Next(c)
+	})
+}
+
+// breakerTransport admits every attempt of a request through the breaker of
+// the endpoint it is sent to, which is not the client's own once it failed
+// over to another region, and records its outcome. It wraps the transport
+// that reaches the network, so that only the errors of the endpoint count as
+// failures.
+type breakerTransport struct {
+	next   http.RoundTripper
+	policy *BreakerPolicy
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := breakerFor(dialedEndpoint(req.URL), t.policy)
+	admitted, ok := b.allow()
+	if !ok {
+		return nil, ErrCircuitOpen
+	}
+
+	res, err := t.next.RoundTrip(req)
+	switch {
+	case err == nil:
+		b.record(admitted, !unhealthyStatus(res.StatusCode))
+	case req.Context().Err() != nil:
+		b.release(admitted)
+	default:
+		b.record(admitted, false)
+	}
+	return res, err
+}
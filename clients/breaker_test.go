@@ -0,0 +1,196 @@
+package clients
+
+import (
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newBreakerClient(t *testing.T, rec *recorder, policy *BreakerPolicy) (*Config, string) {
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+
+	config := testConfig(srv.URL)
+	config.CircuitBreaker = policy
+	return config, srv.URL
+}
+
+func send(t *testing.T, config *Config) error {
+	cl, err := NewClient("vbase", config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Get().AddPath("/file").Send()
+	return err
+}
+
+func TestBreakerOpens(t *testing.T) {
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		rec := &recorder{statuses: []int{status}}
+		config, url := newBreakerClient(t, rec, &BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Hour})
+
+		for i := 0; i < 2; i++ {
+			if err := send(t, config); errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("request %d failed with ErrCircuitOpen before reaching the threshold", i)
+			}
+		}
+		if state := CircuitState(url); state != BreakerOpen {
+			t.Errorf("state after %d responses is %v, want open", status, state)
+		}
+		if err := send(t, config); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("got error %v while open, want ErrCircuitOpen", err)
+		}
+		if rec.count() != 2 {
+			t.Errorf("got %d requests, want 2 as the open circuit sends none", rec.count())
+		}
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	rec := &recorder{statuses: []int{503, 404, 503, 200}}
+	config, url := newBreakerClient(t, rec, &BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Hour})
+
+	for i := 0; i < 4; i++ {
+		send(t, config)
+	}
+	if state := CircuitState(url); state != BreakerClosed {
+		t.Errorf("state is %v, want closed as the failures weren't consecutive", state)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	rec := &recorder{statuses: []int{503, 503, 200}}
+	config, url := newBreakerClient(t, rec, &BreakerPolicy{FailureThreshold: 1, OpenTimeout: 50 * time.Millisecond})
+
+	send(t, config)
+	if state := CircuitState(url); state != BreakerOpen {
+		t.Fatalf("state is %v, want open", state)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if state := CircuitState(url); state != BreakerHalfOpen {
+		t.Fatalf("state after the open timeout is %v, want half-open", state)
+	}
+
+	// A failed probe opens the circuit again
+	if err := send(t, config); !errors.Is(err, ErrServerError) {
+		t.Fatalf("got error %v for the probe, want its ErrServerError", err)
+	}
+	if state := CircuitState(url); state != BreakerOpen {
+		t.Fatalf("state after a failed probe is %v, want open", state)
+	}
+
+	// A successful one closes it
+	time.Sleep(60 * time.Millisecond)
+	if err := send(t, config); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	if state := CircuitState(url); state != BreakerClosed {
+		t.Errorf("state after a successful probe is %v, want closed", state)
+	}
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	b := &breaker{policy: &BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Millisecond, HalfOpenProbes: 2}}
+	b.record(0, false)
+	time.Sleep(2 * time.Millisecond)
+
+	first, ok1 := b.allow()
+	second, ok2 := b.allow()
+	if !ok1 || !ok2 {
+		t.Fatal("half-open breaker rejected its probes")
+	}
+	if _, ok := b.allow(); ok {
+		t.Fatal("half-open breaker allowed more requests than its probes")
+	}
+
+	b.record(first, true)
+	if state := b.currentState(); state != BreakerHalfOpen {
+		t.Fatalf("state after one of two probes is %v, want half-open", state)
+	}
+	b.record(second, true)
+	if state := b.currentState(); state != BreakerClosed {
+		t.Errorf("state after every probe succeeded is %v, want closed", state)
+	}
+}
+
+func TestBreakerIgnoresStaleOutcomes(t *testing.T) {
+	b := &breaker{policy: &BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Millisecond, HalfOpenProbes: 1}}
+
+	// A request admitted while closed is still in flight when another one
+	// opens the circuit
+	slow, _ := b.allow()
+	failed, _ := b.allow()
+	b.record(failed, false)
+	time.Sleep(2 * time.Millisecond)
+
+	probe, ok := b.allow()
+	if !ok {
+		t.Fatal("half-open breaker rejected its probe")
+	}
+	b.record(slow, true)
+	b.release(slow)
+	if _, ok := b.allow(); ok {
+		t.Fatal("a request admitted while closed gave back the slot of the probe")
+	}
+	if state := b.currentState(); state != BreakerHalfOpen {
+		t.Fatalf("state after a request admitted while closed succeeded is %v, want half-open", state)
+	}
+
+	// A probe of a round that is over doesn't count in the next one
+	b.record(probe, false)
+	time.Sleep(2 * time.Millisecond)
+	next, ok := b.allow()
+	if !ok {
+		t.Fatal("half-open breaker rejected the probe of its second round")
+	}
+	b.record(probe, true)
+	if state := b.currentState(); state != BreakerHalfOpen {
+		t.Fatalf("state after a stale probe succeeded is %v, want half-open", state)
+	}
+	b.record(next, true)
+	if state := b.currentState(); state != BreakerClosed {
+		t.Errorf("state after the probe of the round succeeded is %v, want closed", state)
+	}
+}
+
+func TestBreakerIgnoresLocalErrors(t *testing.T) {
+	rec := &recorder{}
+	config, url := newBreakerClient(t, rec, &BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Hour})
+
+	tokenFailure := *config
+	tokenFailure.AuthToken = ""
+	tokenFailure.TokenSource = TokenSourceFunc(func() (*Token, error) {
+		return nil, errors.New("credential file is broken")
+	})
+	if err := send(t, &tokenFailure); err == nil {
+		t.Fatal("request succeeded without a token")
+	}
+
+	cl, _ := NewClient("vbase", config, false)
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	if _, err := WithContext(cl, ctx).Get().AddPath("/file").Send(); err == nil {
+		t.Fatal("cancelled request succeeded")
+	}
+
+	if state := CircuitState(url); state != BreakerClosed {
+		t.Errorf("state is %v, want closed as the endpoint never failed", state)
+	}
+}
+
+func TestBreakerCountsTransportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	config := testConfig(url)
+	config.CircuitBreaker = &BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Hour}
+	send(t, config)
+	if state := CircuitState(url); state != BreakerOpen {
+		t.Errorf("state after a connection error is %v, want open", state)
+	}
+}
@@ -0,0 +1,410 @@
+package clients
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+const headerFromCache = "X-From-Cache"
+
+// Cache stores responses to GET requests so they can be served again while
+// fresh and revalidated with their ETag once stale. Responses are keyed by
+// URL and credential, so that a response is only served again to a caller
+// that sent the same Authorization header. Each credential thus has a private
+// cache of its own: writes only evict the responses cached for theirs.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, res *CachedResponse)
+	Delete(key string)
+}
+
+// CachedResponse is a response kept in a Cache
+type CachedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// Expires is when the response stops being fresh and must be revalidated
+	Expires time.Time `json:"expires"`
+	// Vary holds the request headers named by the Vary header of the
+	// response, which must be the same for it to be served again
+	Vary http.Header `json:"vary,omitempty"`
+}
+
+func (r *CachedResponse) fresh() bool {
+	return time.Now().Before(r.Expires)
+}
+
+// matches reports whether the response may answer req, which must send the
+// same values of the headers it varies on
+func (r *CachedResponse) matches(req *http.Request) bool {
+	for name, values := range r.Vary {
+		if strings.Join(req.Header[name], ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *CachedResponse) response(req *http.Request) *http.Response {
+	header := make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	header.Set(headerFromCache, "1")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// NewLRUCache creates an in-memory Cache that keeps at most maxEntries
+// responses, evicting the least recently used ones.
+func NewLRUCache(maxEntries int) Cache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		index:      map[string]*list.Element{},
+	}
+}
+
+type lruCache struct {
+	sync.Mutex
+	maxEntries int
+	entries    *list.List
+	index      map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	res *CachedResponse
+}
+
+func (c *lruCache) Get(key string) (*CachedResponse, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	c.entries.MoveToFront(el)
+	return el.Value.(*lruEntry).res, true
+}
+
+func (c *lruCache) Set(key string, res *CachedResponse) {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.index[key]; ok {
+		el.Value.(*lruEntry).res = res
+		c.entries.MoveToFront(el)
+		return
+	}
+
+	c.index[key] = c.entries.PushFront(&lruEntry{key, res})
+	for c.maxEntries > 0 && c.entries.Len() > c.maxEntries {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.index, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) Delete(key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.index[key]; ok {
+		c.entries.Remove(el)
+		delete(c.index, key)
+	}
+}
+
+// NewDiskCache creates a Cache that keeps one file per response in dir, so
+// that cached responses survive restarts. The directory is created if needed.
+func NewDiskCache(dir string) (Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir}, nil
+}
+
+type diskCache struct {
+	dir string
+}
+
+func (c *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *diskCache) Get(key string) (*CachedResponse, bool) {
+	buf, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var res CachedResponse
+	if err := json.Unmarshal(buf, &res); err != nil {
+		return nil, false
+	}
+	return &res, true
+}
+
+// Set writes the response to a temporary file first, so that concurrent
+// readers never see a partial entry. The cache is best effort: failing to
+// store a response is not an error for the request that produced it.
+func (c *diskCache) Set(key string, res *CachedResponse) {
+	buf, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(buf)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (c *diskCache) Delete(key string) {
+	os.Remove(c.path(key))
+}
+
+// cachedServices are the services whose responses are cached, the registry
+// being served by apps
+var cachedServices = map[string]bool{
+	"apps":  true,
+	"vbase": true,
+}
+
+// cacheResponses wraps the transport of each request in a cacheTransport
+func cacheResponses(cache Cache) plugin.Plugin {
+	return plugin.NewPhasePlugin("before dial", func(c *context.Context, h context.Handler) {
+		c.Client.Transport = &cacheTransport{next: c.Client.Transport, cache: cache}
+		h.Next(c)
+	})
+}
+
+type cacheTransport struct {
+	next  http.RoundTripper
+	cache Cache
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+
+	// Writes make the cached response of their URL stale, whatever their
+	// outcome, as a failed write may still have happened
+	if !safeMethod(req.Method) {
+		res, err := t.next.RoundTrip(req)
+		t.cache.Delete(key)
+		return res, err
+	}
+
+	// Requests that are conditional already or that ask not to be served
+	// from a cache are left alone.
+	if req.Method != http.MethodGet ||
+		req.Header.Get(HeaderIfNoneMatch) != "" ||
+		req.Header.Get("Range") != "" ||
+		hasDirective(req.Header, "no-cache") ||
+		hasDirective(req.Header, "no-store") {
+		return t.next.RoundTrip(req)
+	}
+
+	cached, ok := t.cache.Get(key)
+	if ok && !cached.matches(req) {
+		cached, ok = nil, false
+	}
+	if ok && cached.fresh() {
+		return cached.response(req), nil
+	}
+
+	outgoing := req
+	if ok && cached.Header.Get(HeaderETag) != "" {
+		outgoing = req.Clone(req.Context())
+		outgoing.Header.Set(HeaderIfNoneMatch, cached.Header.Get(HeaderETag))
+	}
+
+	res, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return res, err
+	}
+
+	if ok && res.StatusCode == http.StatusNotModified {
+		drain(res)
+		revalidated := *cached
+		revalidated.Header = mergeHeaders(cached.Header, res.Header)
+		revalidated.Expires = expiry(res.Header)
+		t.cache.Set(key, &revalidated)
+		return revalidated.response(req), nil
+	}
+
+	if res.StatusCode != http.StatusOK || !storable(res.Header) {
+		if ok {
+			t.cache.Delete(key)
+		}
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.cache.Set(key, &CachedResponse{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       body,
+		Expires:    expiry(res.Header),
+		Vary:       varyHeaders(req, res.Header),
+	})
+	return res, nil
+}
+
+// cacheKey identifies the responses of a URL for one credential, hashed so
+// that caches never hold tokens
+func cacheKey(req *http.Request) string {
+	key := req.URL.String()
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		key = hex.EncodeToString(sum[:16]) + " " + key
+	}
+	return key
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// storable reports whether a response may be cached: it must allow it, not
+// vary on anything but request headers, and be either fresh for some time or
+// revalidatable with an ETag.
+func storable(h http.Header) bool {
+	if hasDirective(h, "no-store") {
+		return false
+	}
+	for _, name := range varyNames(h) {
+		if name == "*" {
+			return false
+		}
+	}
+	return h.Get(HeaderETag) != "" || time.Now().Before(expiry(h))
+}
+
+// varyHeaders returns the values sent by req of the headers a response
+// varies on
+func varyHeaders(req *http.Request, h http.Header) http.Header {
+	names := varyNames(h)
+	if len(names) == 0 {
+		return nil
+	}
+
+	vary := make(http.Header, len(names))
+	for _, name := range names {
+		vary[name] = append([]string{}, req.Header[name]...)
+	}
+	return vary
+}
+
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, value := range h["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// expiry returns until when a response is fresh, according to the max-age of
+// its Cache-Control header.
+func expiry(h http.Header) time.Time {
+	now := time.Now()
+	if hasDirective(h, "no-cache") {
+		return now
+	}
+
+	for _, directive := range cacheDirectives(h) {
+		if strings.HasPrefix(directive, "max-age=") {
+			secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil || secs < 0 {
+				return now
+			}
+			return now.Add(time.Duration(secs) * time.Second)
+		}
+	}
+	return now
+}
+
+func hasDirective(h http.Header, name string) bool {
+	for _, directive := range cacheDirectives(h) {
+		if directive == name {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheDirectives(h http.Header) []string {
+	var directives []string
+	for _, value := range h["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directives = append(directives, strings.ToLower(strings.TrimSpace(directive)))
+		}
+	}
+	return directives
+}
+
+// mergeHeaders updates stored headers with the ones sent along a 304
+func mergeHeaders(stored, updated http.Header) http.Header {
+	merged := make(http.Header, len(stored))
+	for k, v := range stored {
+		merged[k] = v
+	}
+	for k, v := range updated {
+		if k != "Content-Length" {
+			merged[k] = v
+		}
+	}
+	return merged
+}
@@ -0,0 +1,210 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// versionedFile serves a file whose content changes on every PUT, with the
+// given response headers, and counts the GET requests that reach it.
+type versionedFile struct {
+	sync.Mutex
+	header  http.Header
+	version int
+	gets    int
+}
+
+func (f *versionedFile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if r.Method != http.MethodGet {
+		f.version++
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	f.gets++
+	eTag := strconv.Itoa(f.version)
+	for k, v := range f.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(HeaderETag, eTag)
+	if r.Header.Get(HeaderIfNoneMatch) == eTag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write([]byte("version " + eTag))
+}
+
+func (f *versionedFile) getCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.gets
+}
+
+func newCacheServer(t *testing.T, header http.Header) (*versionedFile, *Config) {
+	f := &versionedFile{header: header}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	config := testConfig(srv.URL)
+	config.Cache = NewLRUCache(10)
+	return f, config
+}
+
+func get(t *testing.T, service string, config *Config) (string, bool) {
+	t.Helper()
+	cl, err := NewClient(service, config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := cl.Get().AddPath("/file").Send()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, hit := res.Header[headerFromCache]
+	return res.String(), hit
+}
+
+func TestCacheHit(t *testing.T) {
+	f, config := newCacheServer(t, http.Header{"Cache-Control": {"max-age=60"}})
+
+	get(t, "vbase", config)
+	body, hit := get(t, "vbase", config)
+	if !hit || body != "version 0" || f.getCount() != 1 {
+		t.Errorf("got %q, hit %v after %d requests, want the cached response after 1", body, hit, f.getCount())
+	}
+}
+
+func TestCacheRevalidates(t *testing.T) {
+	f, config := newCacheServer(t, nil)
+
+	get(t, "vbase", config)
+	body, hit := get(t, "vbase", config)
+	if !hit || body != "version 0" || f.getCount() != 2 {
+		t.Errorf("got %q, hit %v after %d requests, want the cached response revalidated with a second one", body, hit, f.getCount())
+	}
+}
+
+func TestCacheEvictsOnWrite(t *testing.T) {
+	_, config := newCacheServer(t, http.Header{"Cache-Control": {"max-age=60"}})
+
+	get(t, "vbase", config)
+	for i, method := range []string{http.MethodPut, http.MethodPost, http.MethodPatch, http.MethodDelete} {
+		cl, _ := NewClient("vbase", config, false)
+		if _, err := cl.Request().Method(method).AddPath("/file").Send(); err != nil {
+			t.Fatal(err)
+		}
+
+		body, hit := get(t, "vbase", config)
+		if hit || body != "version "+strconv.Itoa(i+1) {
+			t.Errorf("got %q, hit %v after a %s, want the new version from the server", body, hit, method)
+		}
+	}
+}
+
+func TestCacheKeyedByCredential(t *testing.T) {
+	f, config := newCacheServer(t, http.Header{"Cache-Control": {"max-age=60"}})
+
+	get(t, "vbase", config)
+
+	other := *config
+	other.AuthToken = "other"
+	if _, hit := get(t, "vbase", &other); hit {
+		t.Error("response cached for one credential was served to another")
+	}
+
+	anonymous := *config
+	anonymous.AuthToken = ""
+	if _, hit := get(t, "vbase", &anonymous); hit {
+		t.Error("response cached for a credential was served without one")
+	}
+
+	if f.getCount() != 3 {
+		t.Errorf("got %d requests, want one per credential", f.getCount())
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	f, config := newCacheServer(t, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}})
+
+	getIn := func(lang string) bool {
+		cl, _ := NewClient("vbase", config, false)
+		res, err := cl.Get().AddPath("/file").SetHeader("Accept-Language", lang).Send()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, hit := res.Header[headerFromCache]
+		return hit
+	}
+
+	getIn("en")
+	if getIn("pt") {
+		t.Error("response varying on Accept-Language was served to another language")
+	}
+	if !getIn("pt") {
+		t.Error("response was not served again to the same language")
+	}
+	if f.getCount() != 2 {
+		t.Errorf("got %d requests, want 2", f.getCount())
+	}
+
+	_, config = newCacheServer(t, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}})
+	get(t, "vbase", config)
+	if _, hit := get(t, "vbase", config); hit {
+		t.Error("response with Vary: * was cached")
+	}
+}
+
+func TestCacheServices(t *testing.T) {
+	for service, cached := range map[string]bool{"apps": true, "vbase": true, "kube-router": false, "colossus": false} {
+		f, config := newCacheServer(t, http.Header{"Cache-Control": {"max-age=60"}})
+
+		get(t, service, config)
+		if _, hit := get(t, service, config); hit != cached {
+			t.Errorf("%s responses cached: %v, want %v", service, hit, cached)
+		}
+		if want := map[bool]int{true: 1, false: 2}[cached]; f.getCount() != want {
+			t.Errorf("%s got %d requests, want %d", service, f.getCount(), want)
+		}
+	}
+}
+
+func TestLRUCacheEvicts(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", &CachedResponse{})
+	c.Set("b", &CachedResponse{})
+	c.Get("a")
+	c.Set("c", &CachedResponse{})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry was kept")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %s was evicted", key)
+		}
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	c, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set("key", &CachedResponse{StatusCode: 200, Body: []byte("content"), Vary: http.Header{"Accept": {"text/plain"}}})
+	res, ok := c.Get("key")
+	if !ok || string(res.Body) != "content" || res.Vary.Get("Accept") != "text/plain" {
+		t.Errorf("got %+v, %v, want the stored response", res, ok)
+	}
+
+	c.Delete("key")
+	if _, ok := c.Get("key"); ok {
+		t.Error("deleted entry is still served")
+	}
+}
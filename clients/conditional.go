@@ -0,0 +1,33 @@
+package clients
+
+import (
+	"errors"
+
+	"gopkg.in/h2non/gentleman.v1/plugin"
+	"gopkg.in/h2non/gentleman.v1/plugins/headers"
+)
+
+const (
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// ErrMissingETag is returned by conditional deletes given an empty ETag, as
+// requiring a resource not to exist makes no sense when deleting it.
+var ErrMissingETag = errors.New("an ETag is required to delete conditionally")
+
+// IfMatch makes a write conditional on the current ETag of the resource. An
+// empty eTag requires the resource not to exist yet. A failed condition is
+// reported as ErrPreconditionFailed.
+func IfMatch(eTag string) plugin.Plugin {
+	if eTag == "" {
+		return headers.Set(HeaderIfNoneMatch, "*")
+	}
+	return headers.Set(HeaderIfMatch, eTag)
+}
+
+// IfNoneMatch makes a read conditional on the resource having changed since
+// eTag. Unchanged resources are answered with a bodiless 304.
+func IfNoneMatch(eTag string) plugin.Plugin {
+	return headers.Set(HeaderIfNoneMatch, eTag)
+}
@@ -0,0 +1,232 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Environment variables read by LoadConfig. They take precedence over the
+// values of the profile file.
+const (
+	EnvAccount   = "VTEX_ACCOUNT"
+	EnvWorkspace = "VTEX_WORKSPACE"
+	EnvRegion    = "VTEX_REGION"
+	EnvEndpoint  = "VTEX_ENDPOINT"
+	EnvToken     = "VTEX_TOKEN"
+	EnvTimeout   = "VTEX_TIMEOUT"
+	// EnvConfigFile and EnvProfile choose the profile file and the profile
+	// in it when they are not given to LoadConfig
+	EnvConfigFile = "VTEX_CONFIG"
+	EnvProfile    = "VTEX_PROFILE"
+)
+
+// DefaultProfile is the profile loaded when none is chosen
+const DefaultProfile = "default"
+
+// Profile is one of the named sets of settings of a profile file. Files map
+// profile names to profiles, as in this YAML file:
+//
+//	default:
+//	  account: myaccount
+//	  workspace: master
+//	  region: aws-us-east-1
+//	  tokenFile: /var/run/secrets/vtex/token
+//	  timeout: 10s
+//
+// JSON files hold the same object, and TOML files one table per profile.
+type Profile struct {
+	Account   string `json:"account" yaml:"account" toml:"account"`
+	Workspace string `json:"workspace" yaml:"workspace" toml:"workspace"`
+	Region    string `json:"region" yaml:"region" toml:"region"`
+	Endpoint  string `json:"endpoint" yaml:"endpoint" toml:"endpoint"`
+	Token     string `json:"token" yaml:"token" toml:"token"`
+	// TokenFile is read by a FileTokenSource when Token is empty
+	TokenFile string `json:"tokenFile" yaml:"tokenFile" toml:"tokenFile"`
+	// Timeout is a duration as in "10s", or a number of seconds
+	Timeout string `json:"timeout" yaml:"timeout" toml:"timeout"`
+}
+
+// LoadConfig builds a Config from a profile of a YAML, JSON or TOML file and
+// the environment. An empty path falls back to VTEX_CONFIG, and no file is
+// read if both are empty. An empty profile falls back to VTEX_PROFILE, then
+// to DefaultProfile.
+//
+// The config gets a RequestContext of its own, so it is ready for processes
+// that don't serve requests. The result is validated with Config.Validate.
+func LoadConfig(path, profile string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv(EnvConfigFile)
+	}
+	if profile == "" {
+		profile = os.Getenv(EnvProfile)
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+
+	var p Profile
+	if path != "" {
+		profiles, err := readProfiles(path)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if p, ok = profiles[profile]; !ok {
+			return nil, fmt.Errorf("Error loading config: profile %q not found in %s", profile, path)
+		}
+	}
+
+	overrideFromEnv(&p.Account, EnvAccount)
+	overrideFromEnv(&p.Workspace, EnvWorkspace)
+	overrideFromEnv(&p.Region, EnvRegion)
+	overrideFromEnv(&p.Endpoint, EnvEndpoint)
+	overrideFromEnv(&p.Timeout, EnvTimeout)
+	if token := os.Getenv(EnvToken); token != "" {
+		p.Token = token
+		p.TokenFile = ""
+	}
+
+	config := &Config{
+		Account:        p.Account,
+		Workspace:      p.Workspace,
+		Region:         p.Region,
+		Endpoint:       p.Endpoint,
+		AuthToken:      p.Token,
+		RequestContext: NewRequestContext(nil),
+	}
+
+	if p.Token == "" && p.TokenFile != "" {
+		config.TokenSource = FileTokenSource(p.TokenFile)
+	}
+
+	if p.Timeout != "" {
+		timeout, err := parseTimeout(p.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading config: invalid timeout %q", p.Timeout)
+		}
+		config.Timeout = timeout
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("Error loading config: %w", err)
+	}
+	return config, nil
+}
+
+func readProfiles(path string) (map[string]Profile, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading config file: %v", err)
+	}
+
+	var profiles map[string]Profile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(buf, &profiles)
+	case ".json":
+		err = json.Unmarshal(buf, &profiles)
+	case ".toml":
+		err = toml.Unmarshal(buf, &profiles)
+	default:
+		return nil, fmt.Errorf("Error reading config file: unknown format of %s, expected .yaml, .yml, .json or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing config file %s: %v", path, err)
+	}
+	return profiles, nil
+}
+
+func overrideFromEnv(value *string, name string) {
+	if v := os.Getenv(name); v != "" {
+		*value = v
+	}
+}
+
+func parseTimeout(value string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Validate reports the first setting that is missing or invalid for any
+// client. Clients check the settings specific to their service, such as the
+// workspace of the ones bound to it, on creation.
+func (config *Config) Validate() error {
+	if config == nil {
+		return errors.New("config is required")
+	}
+	if config.RequestContext == nil {
+		return errors.New("request context is required")
+	}
+	if config.Account == "" {
+		return errors.New("account is required")
+	}
+	if config.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %v", config.Timeout)
+	}
+	if config.Endpoint != "" {
+		if err := checkEndpoint(config.Endpoint, config); err != nil {
+			return err
+		}
+	}
+	for service, e := range config.Endpoints {
+		if err := checkEndpoint(e, config); err != nil {
+			return fmt.Errorf("%v for %s", err, service)
+		}
+	}
+	if config.Endpoint == "" && len(config.Endpoints) == 0 && config.needsRegion() {
+		return errors.New("region or endpoint is required")
+	}
+	return nil
+}
+
+// validateFor reports the first setting that is missing or invalid for the
+// client of a service
+func (config *Config) validateFor(service string, workspaceBound bool) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if workspaceBound && config.Workspace == "" {
+		return errors.New("workspace is required")
+	}
+	if config.Endpoint == "" && config.Endpoints[service] == "" && config.needsRegion() {
+		return errors.New("region or endpoint is required")
+	}
+	return checkEndpoint(endpoint(service, config), config)
+}
+
+// needsRegion reports whether endpoints built from the template lack a region
+func (config *Config) needsRegion() bool {
+	if config.Region != "" || len(config.Regions) > 0 {
+		return false
+	}
+	template := config.EndpointTemplate
+	if template == "" {
+		template = defaultEndpointTemplate
+	}
+	return strings.Contains(template, "{region}")
+}
+
+func checkEndpoint(e string, config *Config) error {
+	raw := e
+	if !strings.Contains(raw, "://") {
+		raw = withScheme(raw, config)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid endpoint %q", e)
+	}
+	return nil
+}
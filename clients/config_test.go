@@ -0,0 +1,171 @@
+package clients
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreateClientKeepsBaselineChecks(t *testing.T) {
+	config := &Config{Region: "aws-us-east-1", RequestContext: NewRequestContext(nil)}
+	CreateClient("vbase", config, true)
+	if config.Timeout != 0 {
+		t.Errorf("CreateClient set the caller's timeout to %v", config.Timeout)
+	}
+
+	for name, config := range map[string]*Config{
+		"a nil config":          nil,
+		"a nil request context": {Account: "account", Region: "aws-us-east-1"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("CreateClient didn't panic with %s", name)
+				}
+			}()
+			CreateClient("vbase", config, true)
+		}()
+	}
+}
+
+func TestNewClientValidates(t *testing.T) {
+	valid := testConfig("http://localhost")
+	if _, err := NewClient("vbase", valid, true); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	for name, change := range map[string]func(*Config){
+		"no account":          func(c *Config) { c.Account = "" },
+		"no workspace":        func(c *Config) { c.Workspace = "" },
+		"a relative endpoint": func(c *Config) { c.Endpoint = "/vbase" },
+		"an ftp endpoint":     func(c *Config) { c.Endpoint = "ftp://localhost" },
+		"no region":           func(c *Config) { c.Endpoint = "" },
+		"a negative timeout":  func(c *Config) { c.Timeout = -1 },
+	} {
+		config := *valid
+		change(&config)
+		if _, err := NewClient("vbase", &config, true); err == nil {
+			t.Errorf("config with %s was accepted", name)
+		}
+	}
+}
+
+// clearConfigEnv unsets the variables read by LoadConfig for the test
+func clearConfigEnv(t *testing.T) {
+	for _, name := range []string{EnvAccount, EnvWorkspace, EnvRegion, EnvEndpoint, EnvToken, EnvTimeout, EnvConfigFile, EnvProfile} {
+		t.Setenv(name, "")
+	}
+}
+
+func writeProfiles(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	clearConfigEnv(t)
+
+	for name, content := range map[string]string{
+		"profiles.yaml": "default:\n  account: acc\n  workspace: dev\n  region: aws-us-east-1\n  token: secret\n  timeout: 3s\n",
+		"profiles.json": `{"default": {"account": "acc", "workspace": "dev", "region": "aws-us-east-1", "token": "secret", "timeout": "3s"}}`,
+		"profiles.toml": "[default]\naccount = \"acc\"\nworkspace = \"dev\"\nregion = \"aws-us-east-1\"\ntoken = \"secret\"\ntimeout = \"3s\"\n",
+	} {
+		config, err := LoadConfig(writeProfiles(t, name, content), "")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if config.Account != "acc" || config.Workspace != "dev" || config.Region != "aws-us-east-1" ||
+			config.AuthToken != "secret" || config.Timeout != 3*time.Second || config.RequestContext == nil {
+			t.Errorf("%s was loaded as %+v", name, config)
+		}
+	}
+
+	if _, err := LoadConfig(writeProfiles(t, "profiles.ini", "account=acc"), ""); err == nil {
+		t.Error("file of an unknown format was loaded")
+	}
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeProfiles(t, "profiles.yaml", `
+default:
+  account: acc
+  region: aws-us-east-1
+staging:
+  account: staging
+  endpoint: http://localhost:8080
+  tokenFile: /var/run/secrets/token
+`)
+
+	config, err := LoadConfig(path, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Account != "staging" || config.Endpoint != "http://localhost:8080" || config.TokenSource == nil {
+		t.Errorf("staging profile was loaded as %+v", config)
+	}
+
+	t.Setenv(EnvProfile, "staging")
+	if config, err := LoadConfig(path, ""); err != nil || config.Account != "staging" {
+		t.Errorf("got %+v, %v, want the profile of %s", config, err, EnvProfile)
+	}
+
+	if _, err := LoadConfig(path, "missing"); err == nil {
+		t.Error("missing profile was loaded")
+	}
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeProfiles(t, "profiles.yaml", "default:\n  account: acc\n  region: aws-us-east-1\n  tokenFile: /var/run/secrets/token\n")
+	t.Setenv(EnvConfigFile, path)
+	t.Setenv(EnvAccount, "other")
+	t.Setenv(EnvWorkspace, "dev")
+	t.Setenv(EnvToken, "secret")
+	t.Setenv(EnvTimeout, "3")
+
+	config, err := LoadConfig("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Account != "other" || config.Workspace != "dev" || config.Region != "aws-us-east-1" {
+		t.Errorf("got %+v, want the environment to override the file", config)
+	}
+	if config.AuthToken != "secret" || config.TokenSource != nil {
+		t.Errorf("got token %q and source %v, want %s over the token file", config.AuthToken, config.TokenSource, EnvToken)
+	}
+	if config.Timeout != 3*time.Second {
+		t.Errorf("timeout %q was read as %v, want 3s", "3", config.Timeout)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	clearConfigEnv(t)
+
+	for name, content := range map[string]string{
+		"no account":          "default:\n  region: aws-us-east-1\n",
+		"no region":           "default:\n  account: acc\n",
+		"a relative endpoint": "default:\n  account: acc\n  endpoint: /vbase\n",
+		"an invalid timeout":  "default:\n  account: acc\n  region: aws-us-east-1\n  timeout: soon\n",
+	} {
+		if _, err := LoadConfig(writeProfiles(t, "profiles.yaml", content), ""); err == nil {
+			t.Errorf("profile with %s was loaded", name)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	for value, want := range map[string]time.Duration{"3": 3 * time.Second, "3s": 3 * time.Second, "500ms": 500 * time.Millisecond} {
+		if got, err := parseTimeout(value); err != nil || got != want {
+			t.Errorf("parseTimeout(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	if _, err := parseTimeout("3 seconds"); err == nil {
+		t.Error("parseTimeout accepted an invalid value")
+	}
+}
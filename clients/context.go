@@ -0,0 +1,27 @@
+package clients
+
+import (
+	stdcontext "context"
+
+	"gopkg.in/h2non/gentleman.v1"
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// WithContext returns a child of cl whose requests are bound to ctx. They are
+// cancelled as soon as ctx is done and honor its deadline, on top of the
+// Config.Timeout that still applies to every request.
+func WithContext(cl *gentleman.Client, ctx stdcontext.Context) *gentleman.Client {
+	if ctx == nil {
+		panic("ctx cannot be <nil>")
+	}
+
+	return newGentlemanClient().UseParent(cl).Use(bindContext(ctx))
+}
+
+func bindContext(ctx stdcontext.Context) plugin.Plugin {
+	return plugin.NewRequestPlugin(func(c *context.Context, h context.Handler) {
+		c.Request = c.Request.WithContext(ctx)
+		h.Next(c)
+	})
+}
@@ -0,0 +1,76 @@
+package clients
+
+import (
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newBlockingServer(t *testing.T) (*httptest.Server, chan struct{}) {
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		srv.Close()
+	})
+	return srv, arrived
+}
+
+func TestWithContextCancelsInFlightRequest(t *testing.T) {
+	srv, arrived := newBlockingServer(t)
+	cl, err := NewClient("vbase", testConfig(srv.URL), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	go func() {
+		<-arrived
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := WithContext(cl, ctx).Get().AddPath("/file").Send()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, stdcontext.Canceled) {
+			t.Errorf("error is %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not aborted when its context was cancelled")
+	}
+}
+
+func TestWithContextHonorsDeadline(t *testing.T) {
+	srv, _ := newBlockingServer(t)
+	cl, err := NewClient("vbase", testConfig(srv.URL), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = WithContext(cl, ctx).Get().AddPath("/file").Send()
+	if !errors.Is(err, stdcontext.DeadlineExceeded) {
+		t.Errorf("error is %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %v, want it aborted at the deadline", elapsed)
+	}
+}
@@ -0,0 +1,80 @@
+package clients
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEndpointScheme(t *testing.T) {
+	for _, c := range []struct {
+		config *Config
+		want   string
+	}{
+		{&Config{Region: "aws-us-east-1"}, "http://vbase.aws-us-east-1.vtex.io"},
+		{&Config{Region: "aws-us-east-1", Scheme: "https"}, "https://vbase.aws-us-east-1.vtex.io"},
+		{&Config{Endpoint: "gateway.local:8080/", Scheme: "https"}, "https://gateway.local:8080"},
+		{&Config{Endpoint: "http://gateway.local", Scheme: "https"}, "http://gateway.local"},
+		{&Config{Region: "eu", Account: "acc", EndpointTemplate: "{account}.{service}.{region}.example.com"}, "http://acc.vbase.eu.example.com"},
+	} {
+		if got := endpoint("vbase", c.config); got != c.want {
+			t.Errorf("endpoint of %+v is %q, want %q", c.config, got, c.want)
+		}
+	}
+}
+
+func TestEndpointsOverride(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	config := testConfig("")
+	config.Region = "aws-us-east-1"
+	config.Endpoints = map[string]string{"vbase": srv.URL}
+
+	if got := endpoint("apps", config); got != "http://apps.aws-us-east-1.vtex.io" {
+		t.Errorf("endpoint of apps is %q, want the template's", got)
+	}
+	if err := send(t, config); err != nil {
+		t.Fatal(err)
+	}
+	if rec.count() != 1 {
+		t.Errorf("override got %d requests, want 1", rec.count())
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	config := testConfig(srv.URL)
+	if err := send(t, config); err == nil {
+		t.Fatal("request to a server of an unknown CA succeeded")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	config.TLSConfig = &tls.Config{RootCAs: pool}
+	atomic.StoreInt32(&conns, 0)
+
+	// The clients created for each request share the connections
+	for i := 0; i < 3; i++ {
+		if err := send(t, config); err != nil {
+			t.Fatalf("request with the server's CA failed: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("clients of the same TLS config opened %d connections, want 1", n)
+	}
+}
@@ -1,16 +1,35 @@
 package clients
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// Sentinel errors matched by ResponseError according to its status code, for
+// use with errors.Is.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrServerError        = errors.New("server error")
 )
 
 type ResponseError struct {
 	Response   *http.Response
+	Service    string
 	StatusCode int
 	Code       string
 	Message    string
+	// Body is the raw body of the response, which was read to build the error
+	Body []byte
 }
 
 func (err ResponseError) Error() string {
@@ -20,3 +39,66 @@ func (err ResponseError) Error() string {
 	}
 	return fmt.Sprintf("(%d %v at %v) %v", err.StatusCode, err.Code, url, err.Message)
 }
+
+// Unwrap returns the sentinel error matching the status code, if any
+func (err ResponseError) Unwrap() error {
+	switch {
+	case err.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case err.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case err.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case err.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case err.StatusCode == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case err.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case err.StatusCode >= http.StatusInternalServerError:
+		return ErrServerError
+	}
+	return nil
+}
+
+// RequestError is returned when a request fails without a response, e.g. on
+// network errors, timeouts or an open circuit.
+type RequestError struct {
+	Service string
+	Method  string
+	URL     *url.URL
+	Err     error
+}
+
+func (err *RequestError) Error() string {
+	return fmt.Sprintf("(%v %v at %v) %v", err.Service, err.Method, err.URL, err.Err)
+}
+
+func (err *RequestError) Unwrap() error {
+	return err.Err
+}
+
+func wrapErrors(service string) plugin.Plugin {
+	return plugin.NewErrorPlugin(func(c *context.Context, h context.Handler) {
+		var respErr ResponseError
+		var reqErr *RequestError
+		if c.Error == nil || errors.As(c.Error, &respErr) || errors.As(c.Error, &reqErr) {
+			h.Next(c)
+			return
+		}
+
+		err := c.Error
+		// The request is already described, so keep only the cause.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+
+		c.Error = &RequestError{
+			Service: service,
+			Method:  c.Request.Method,
+			URL:     c.Request.URL,
+			Err:     err,
+		}
+		h.Next(c)
+	})
+}
@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseErrorSentinels(t *testing.T) {
+	sentinels := []error{ErrNotFound, ErrConflict, ErrUnauthorized, ErrForbidden, ErrPreconditionFailed, ErrRateLimited, ErrServerError}
+
+	for status, want := range map[int]error{
+		http.StatusBadRequest:          nil,
+		http.StatusUnauthorized:        ErrUnauthorized,
+		http.StatusForbidden:           ErrForbidden,
+		http.StatusNotFound:            ErrNotFound,
+		http.StatusConflict:            ErrConflict,
+		http.StatusPreconditionFailed:  ErrPreconditionFailed,
+		http.StatusTooManyRequests:     ErrRateLimited,
+		http.StatusInternalServerError: ErrServerError,
+		http.StatusBadGateway:          ErrServerError,
+		http.StatusServiceUnavailable:  ErrServerError,
+	} {
+		srv := httptest.NewServer(&recorder{statuses: []int{status}})
+		err := send(t, testConfig(srv.URL))
+		srv.Close()
+
+		var respErr ResponseError
+		if !errors.As(err, &respErr) || respErr.StatusCode != status || respErr.Service != "vbase" {
+			t.Errorf("%d: got error %v, want a ResponseError of vbase", status, err)
+		}
+		for _, sentinel := range sentinels {
+			if errors.Is(err, sentinel) != (sentinel == want) {
+				t.Errorf("%d: errors.Is(err, %v) = %v, want %v", status, sentinel, !(sentinel == want), sentinel == want)
+			}
+		}
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	err := send(t, testConfig(url))
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("got error %v, want a RequestError", err)
+	}
+	if reqErr.Service != "vbase" || reqErr.Method != http.MethodGet || reqErr.URL == nil || reqErr.URL.Path != "/file" {
+		t.Errorf("got %+v, want the failed request described", reqErr)
+	}
+
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" {
+		t.Errorf("got cause %v, want the dial error", reqErr.Err)
+	}
+	var respErr ResponseError
+	if errors.As(err, &respErr) {
+		t.Error("error without a response matched ResponseError")
+	}
+}
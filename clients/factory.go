@@ -0,0 +1,137 @@
+package clients
+
+import (
+	"container/list"
+	"sync"
+
+	"gopkg.in/h2non/gentleman.v1"
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/middleware"
+)
+
+// maxSharedClients is how many shared clients a Factory keeps, evicting the
+// least recently used ones. Clients built on an evicted one keep working.
+const maxSharedClients = 256
+
+// Factory is meant to live as long as the process. It holds what clients
+// created for different requests can share: the transport with its connection
+// pool, and per service, account and workspace a client with every plugin
+// that doesn't depend on the request being served. The configs it returns
+// make constructors such as vbase.NewClient cheap, as they only add the
+// request-scoped plugins on top.
+//
+// The Cache of the base config is shared too. It keys responses by
+// credential, so callers are never served the responses of another one.
+type Factory struct {
+	sync.Mutex
+	config  Config
+	clients *list.List
+	index   map[sharedKey]*list.Element
+}
+
+type sharedKey struct {
+	service        string
+	workspaceBound bool
+	account        string
+	workspace      string
+}
+
+type sharedEntry struct {
+	key    sharedKey
+	client *gentleman.Client
+}
+
+// NewFactory creates a Factory from a base config. Its RequestContext and
+// credentials are ignored in favor of the ones given to Config. When the
+// base config has no Transport, the factory creates one for its clients with
+// the base TLSConfig.
+func NewFactory(config *Config) *Factory {
+	if config == nil {
+		panic("config cannot be <nil>")
+	}
+
+	base := *config
+	base.RequestContext = nil
+	if base.Transport == nil {
+		base.Transport = newTransport(&base)
+	}
+
+	return &Factory{
+		config:  base,
+		clients: list.New(),
+		index:   map[sharedKey]*list.Element{},
+	}
+}
+
+// Config returns a config for the clients acting on behalf of one request,
+// authenticated with authToken unless it is empty. Of the returned config,
+// only the account, workspace, credentials and RequestContext may be changed
+// before creating clients; the rest of the factory's setup is shared.
+func (f *Factory) Config(authToken string, reqCtx RequestContext) *Config {
+	config := f.config
+	if authToken != "" {
+		config.AuthToken = authToken
+		config.AuthFunc = nil
+		config.TokenSource = nil
+	}
+	config.RequestContext = reqCtx
+	config.factory = f
+	return &config
+}
+
+func (f *Factory) sharedClient(service string, config *Config, workspaceBound bool) *gentleman.Client {
+	key := sharedKey{service, workspaceBound, config.Account, config.Workspace}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if el, ok := f.index[key]; ok {
+		f.clients.MoveToFront(el)
+		return el.Value.(*sharedEntry).client
+	}
+
+	base := f.config
+	base.Account = config.Account
+	base.Workspace = config.Workspace
+	cl := sharedClient(service, &base, workspaceBound)
+
+	f.index[key] = f.clients.PushFront(&sharedEntry{key, cl})
+	for f.clients.Len() > maxSharedClients {
+		oldest := f.clients.Back()
+		f.clients.Remove(oldest)
+		delete(f.index, oldest.Value.(*sharedEntry).key)
+	}
+	return cl
+}
+
+// newGentlemanClient creates a gentleman client that can be the parent of
+// concurrent requests and clients.
+func newGentlemanClient() *gentleman.Client {
+	cl := gentleman.New()
+	cl.Middleware = &concurrentLayer{Layer: middleware.New()}
+	return cl
+}
+
+// concurrentLayer is a middleware layer that can run for many requests at
+// once. gentleman's Layer rewrites its stack on every run, which races when
+// it is the parent of concurrent requests, so each run goes through a
+// throwaway layer with the same plugins instead. Plugins may only be added
+// before the layer is used.
+type concurrentLayer struct {
+	*middleware.Layer
+	parent middleware.Middleware
+}
+
+func (l *concurrentLayer) UseParent(parent middleware.Middleware) middleware.Middleware {
+	l.parent = parent
+	return l
+}
+
+func (l *concurrentLayer) Run(phase string, ctx *context.Context) *context.Context {
+	run := middleware.New()
+	run.SetStack(l.GetStack())
+	if l.parent != nil {
+		run.UseParent(l.parent)
+	}
+	return run.Run(phase, ctx)
+}
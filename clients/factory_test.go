@@ -0,0 +1,70 @@
+package clients
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestFactoryConcurrentCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	base := testConfig(srv.URL)
+	base.Cache = NewLRUCache(100)
+	base.RetryPolicy = fastRetries()
+	factory := NewFactory(base)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 100; i++ {
+		for _, token := range []string{"alice", "bob"} {
+			wg.Add(1)
+			go func(token string) {
+				defer wg.Done()
+
+				cl, err := NewClient("vbase", factory.Config(token, NewRequestContext(nil)), true)
+				if err != nil {
+					errs <- err
+					return
+				}
+				res, err := cl.Get().AddPath("/buckets/b/files/f").Send()
+				if err != nil {
+					errs <- err
+				} else if got := res.String(); got != "Bearer "+token {
+					errs <- fmt.Errorf("%s was served the response of %q", token, got)
+				}
+			}(token)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestFactoryEvictsSharedClients(t *testing.T) {
+	factory := NewFactory(testConfig("http://localhost"))
+
+	first := factory.Config("", NewRequestContext(nil))
+	firstClient := factory.sharedClient("vbase", first, true)
+	for i := 0; i < maxSharedClients+10; i++ {
+		config := factory.Config("", NewRequestContext(nil))
+		config.Workspace = fmt.Sprintf("workspace%d", i)
+		factory.sharedClient("vbase", config, true)
+	}
+
+	if n := factory.clients.Len(); n != maxSharedClients || len(factory.index) != maxSharedClients {
+		t.Errorf("factory keeps %d clients, want at most %d", n, maxSharedClients)
+	}
+	if factory.sharedClient("vbase", first, true) == firstClient {
+		t.Error("least recently used client was kept")
+	}
+}
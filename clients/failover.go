@@ -0,0 +1,193 @@
+package clients
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// regionCooldown is how long a region is avoided after it fails a request
+const regionCooldown = 30 * time.Second
+
+// regionHealth tracks whether the endpoint of a service in one region is
+// currently failing.
+type regionHealth struct {
+	sync.Mutex
+	unhealthyUntil time.Time
+}
+
+func (h *regionHealth) healthy() bool {
+	h.Lock()
+	defer h.Unlock()
+	return !time.Now().Before(h.unhealthyUntil)
+}
+
+func (h *regionHealth) record(failed bool) {
+	h.Lock()
+	defer h.Unlock()
+	if failed {
+		h.unhealthyUntil = time.Now().Add(regionCooldown)
+	} else {
+		h.unhealthyUntil = time.Time{}
+	}
+}
+
+// regionHealths holds the health of every regional endpoint, keyed by its URL,
+// so that it outlives the clients created for each request.
+var regionHealths = struct {
+	sync.Mutex
+	m map[string]*regionHealth
+}{m: map[string]*regionHealth{}}
+
+func regionHealthFor(endpoint string) *regionHealth {
+	regionHealths.Lock()
+	defer regionHealths.Unlock()
+
+	h, ok := regionHealths.m[endpoint]
+	if !ok {
+		h = &regionHealth{}
+		regionHealths.m[endpoint] = h
+	}
+	return h
+}
+
+type regionEndpoint struct {
+	region string
+	url    *url.URL
+	health *regionHealth
+}
+
+// regionEndpoints returns the endpoints of service in each of the regions of
+// config, or nil when its endpoint doesn't depend on the region.
+func regionEndpoints(service string, config *Config) []*regionEndpoint {
+	if len(config.Regions) == 0 || service == "" || config.Endpoint != "" || config.Endpoints[service] != "" {
+		return nil
+	}
+
+	endpoints := make([]*regionEndpoint, 0, len(config.Regions))
+	for _, region := range config.Regions {
+		endpoint := templateEndpoint(service, region, config)
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			continue
+		}
+		endpoints = append(endpoints, &regionEndpoint{region, u, regionHealthFor(endpoint)})
+	}
+	return endpoints
+}
+
+const failoverTransportKey = "failoverTransport"
+
+// failoverRequests wraps the transport of each request in a failoverTransport.
+// Requests are built against the first region, whose endpoint is the client's
+// base URL.
+func failoverRequests(endpoints []*regionEndpoint) plugin.Plugin {
+	return plugin.NewPhasePlugin("before dial", func(c *context.Context, h context.Handler) {
+		ft := &failoverTransport{next: c.Client.Transport, endpoints: endpoints}
+		c.Client.Transport = ft
+		c.Set(failoverTransportKey, ft)
+		h.Next(c)
+	})
+}
+
+// servedRegion returns the region that served the request of c, if the
+// client fails over between regions
+func servedRegion(c *context.Context) string {
+	if ft, ok := c.GetOk(failoverTransportKey); ok {
+		return ft.(*failoverTransport).region
+	}
+	return ""
+}
+
+// failoverTransport sends each request to the first healthy region. Idempotent
+// requests that fail with a transport error or a 5xx response are sent again
+// to the next region, if their body can be; the others are never sent twice.
+type failoverTransport struct {
+	next      http.RoundTripper
+	endpoints []*regionEndpoint
+	region    string
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	candidates := t.candidates()
+	if !IsIdempotent(req) {
+		candidates = candidates[:1]
+	}
+
+	var body *replayableBody
+	if len(candidates) > 1 {
+		var err error
+		if body, err = replayBody(req, defaultMaxBodySize); err != nil {
+			return nil, err
+		}
+		if !body.replayable {
+			candidates = candidates[:1]
+		}
+	}
+
+	primary := t.endpoints[0].url
+	for i, e := range candidates {
+		outgoing := req
+		if body != nil {
+			var err error
+			if outgoing, err = body.rewind(req); err != nil {
+				return nil, err
+			}
+		}
+
+		t.region = e.region
+		res, err := t.next.RoundTrip(e.rewrite(outgoing, primary))
+		if err != nil && req.Context().Err() != nil {
+			// The caller gave up, which says nothing about the region.
+			return res, err
+		}
+
+		failed := err != nil || res.StatusCode >= http.StatusInternalServerError
+		e.health.record(failed)
+		if !failed || i == len(candidates)-1 {
+			return res, err
+		}
+
+		if res != nil {
+			drain(res)
+		}
+	}
+	return nil, nil
+}
+
+// candidates orders the regions by preference, healthy ones first, keeping
+// the unhealthy ones as a last resort.
+func (t *failoverTransport) candidates() []*regionEndpoint {
+	healthy := make([]*regionEndpoint, 0, len(t.endpoints))
+	var unhealthy []*regionEndpoint
+	for _, e := range t.endpoints {
+		if e.health.healthy() {
+			healthy = append(healthy, e)
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// rewrite returns a copy of req sent to this region instead of the primary one
+func (e *regionEndpoint) rewrite(req *http.Request, primary *url.URL) *http.Request {
+	if e.url == primary {
+		return req
+	}
+
+	clone := req.Clone(req.Context())
+	u := *req.URL
+	u.Scheme = e.url.Scheme
+	u.Host = e.url.Host
+	u.Path = e.url.Path + strings.TrimPrefix(u.Path, primary.Path)
+	u.RawPath = ""
+	clone.URL = &u
+	clone.Host = u.Host
+	return clone
+}
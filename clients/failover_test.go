@@ -0,0 +1,173 @@
+package clients
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRegions starts a server per region, answering with the given statuses,
+// and returns a config failing over between them in order. The regions are
+// named after the hosts of the servers.
+func newRegions(t *testing.T, statuses ...[]int) (*Config, []*recorder) {
+	config := &Config{
+		Account:          "account",
+		Workspace:        "master",
+		EndpointTemplate: "{region}",
+		AuthToken:        "token",
+	}
+
+	var recs []*recorder
+	for _, s := range statuses {
+		rec := &recorder{statuses: s}
+		srv := httptest.NewServer(rec)
+		t.Cleanup(srv.Close)
+
+		recs = append(recs, rec)
+		config.Regions = append(config.Regions, strings.TrimPrefix(srv.URL, "http://"))
+	}
+
+	incoming, _ := http.NewRequest(http.MethodGet, "/", nil)
+	incoming.Header.Set(enableTraceHeader, "true")
+	config.RequestContext = NewRequestContext(incoming)
+	return config, recs
+}
+
+func tracedRegions(config *Config) []string {
+	var trees []*CallTree
+	json.Unmarshal([]byte(config.RequestContext.GetS(HeaderCallTrace)), &trees)
+
+	regions := make([]string, len(trees))
+	for i, tree := range trees {
+		regions[i] = tree.Region
+	}
+	return regions
+}
+
+func TestFailover(t *testing.T) {
+	config, recs := newRegions(t, []int{503}, []int{200})
+
+	cl, err := NewClient("vbase", config, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.Put().AddPath("/file").BodyString("content").Send(); err != nil {
+		t.Fatalf("PUT failed despite a healthy region: %v", err)
+	}
+	if recs[0].count() != 1 || recs[1].count() != 1 {
+		t.Fatalf("regions got %d and %d requests, want 1 each", recs[0].count(), recs[1].count())
+	}
+	if got := recs[1].requests[0]; got.body != "content" {
+		t.Errorf("failover sent body %q, want the whole body", got.body)
+	}
+
+	// The failed region is avoided from then on
+	if _, err := cl.Get().AddPath("/file").Send(); err != nil {
+		t.Fatal(err)
+	}
+	if recs[0].count() != 1 || recs[1].count() != 2 {
+		t.Errorf("regions got %d and %d requests, want the unhealthy one skipped", recs[0].count(), recs[1].count())
+	}
+
+	want := []string{config.Regions[1], config.Regions[1]}
+	if got := tracedRegions(config); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("traced regions %v, want %v", got, want)
+	}
+}
+
+func TestFailoverOnlyIdempotent(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		config, recs := newRegions(t, []int{503}, []int{200})
+
+		cl, _ := NewClient("vbase", config, true)
+		if _, err := cl.Request().Method(method).AddPath("/file").Send(); err == nil {
+			t.Errorf("%s succeeded, want the error of the primary region", method)
+		}
+		if recs[0].count() != 1 || recs[1].count() != 0 {
+			t.Errorf("%s was sent %d and %d times, want only to the primary region", method, recs[0].count(), recs[1].count())
+		}
+	}
+}
+
+func TestFailoverLastResort(t *testing.T) {
+	config, recs := newRegions(t, []int{503}, []int{502})
+
+	cl, _ := NewClient("vbase", config, true)
+	if _, err := cl.Get().AddPath("/file").Send(); err == nil {
+		t.Fatal("GET succeeded, want the error of the last region")
+	}
+
+	// With every region unhealthy, they are still tried in order
+	cl.Get().AddPath("/file").Send()
+	if recs[0].count() != 2 || recs[1].count() != 2 {
+		t.Errorf("regions got %d and %d requests, want 2 each", recs[0].count(), recs[1].count())
+	}
+}
+
+func TestFailoverRecovers(t *testing.T) {
+	config, recs := newRegions(t, []int{503, 200}, []int{200})
+
+	cl, _ := NewClient("vbase", config, true)
+	cl.Get().AddPath("/file").Send()
+
+	regionHealthFor("http://" + config.Regions[0]).record(false)
+	cl.Get().AddPath("/file").Send()
+	if recs[0].count() != 2 || recs[1].count() != 1 {
+		t.Errorf("regions got %d and %d requests, want the recovered primary preferred again", recs[0].count(), recs[1].count())
+	}
+}
+
+func TestFailoverBreakerPerRegion(t *testing.T) {
+	config, _ := newRegions(t, []int{503}, []int{200})
+	config.CircuitBreaker = &BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Hour}
+
+	cl, _ := NewClient("vbase", config, true)
+	if _, err := cl.Get().AddPath("/file").Send(); err != nil {
+		t.Fatalf("GET failed despite a healthy region: %v", err)
+	}
+
+	if state := CircuitState("http://" + config.Regions[0]); state != BreakerOpen {
+		t.Errorf("state of the failed region is %v, want open", state)
+	}
+	if state := CircuitState("http://" + config.Regions[1]); state != BreakerClosed {
+		t.Errorf("state of the region that served the request is %v, want closed", state)
+	}
+}
+
+func TestFailoverRateLimitPerRegion(t *testing.T) {
+	config, recs := newRegions(t, []int{503}, []int{200})
+	config.RateLimits = map[string]*RateLimit{"vbase": {RequestsPerSecond: 1}}
+
+	cl, _ := NewClient("vbase", config, true)
+	start := time.Now()
+	if _, err := cl.Get().AddPath("/file").Send(); err != nil {
+		t.Fatal(err)
+	}
+	if recs[1].count() != 1 {
+		t.Fatalf("failover region got %d requests, want 1", recs[1].count())
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GET took %v, want the failover region not to wait on the budget of the primary", elapsed)
+	}
+}
+
+func TestTracedRegionWithoutFailover(t *testing.T) {
+	srv := httptest.NewServer(&recorder{})
+	defer srv.Close()
+
+	config, _ := newRegions(t)
+	config.Regions = nil
+	config.Region = "aws-us-east-1"
+	config.Endpoint = srv.URL
+
+	cl, _ := NewClient("vbase", config, true)
+	if _, err := cl.Get().AddPath("/file").Send(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tracedRegions(config); len(got) != 1 || got[0] != "aws-us-east-1" {
+		t.Errorf("traced regions %v, want the configured one", got)
+	}
+}
@@ -0,0 +1,96 @@
+package clients
+
+import (
+	stdcontext "context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// Fields are the structured attributes of a log event
+type Fields map[string]interface{}
+
+// Logger receives the events logged by the clients. Every request is logged
+// at debug level with its service, method, path, status, latency and retry
+// count.
+type Logger interface {
+	Debug(msg string, fields Fields)
+	Error(msg string, fields Fields)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields Fields) {}
+func (nopLogger) Error(msg string, fields Fields) {}
+
+// NewSlogLogger adapts a log/slog logger to Logger
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s *slogLogger) Debug(msg string, fields Fields) {
+	s.l.LogAttrs(stdcontext.Background(), slog.LevelDebug, msg, attrs(fields)...)
+}
+
+func (s *slogLogger) Error(msg string, fields Fields) {
+	s.l.LogAttrs(stdcontext.Background(), slog.LevelError, msg, attrs(fields)...)
+}
+
+func attrs(fields Fields) []slog.Attr {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, fields[k]))
+	}
+	return attrs
+}
+
+func logRequests(service string, logger Logger) plugin.Plugin {
+	const startTime = "logStartTime"
+
+	log := func(c *context.Context) {
+		fields := Fields{
+			"service": service,
+			"method":  c.Request.Method,
+			"path":    c.Request.URL.Path,
+			"status":  c.Response.StatusCode,
+			"retries": retries(c),
+		}
+		if start, ok := c.GetOk(startTime); ok {
+			fields["latency"] = time.Since(start.(time.Time))
+		}
+		if c.Error != nil {
+			fields["error"] = c.Error.Error()
+		}
+		logger.Debug("Request to "+service, fields)
+	}
+
+	p := plugin.New()
+	p.SetHandlers(plugin.Handlers{
+		"before dial": func(c *context.Context, h context.Handler) {
+			c.Set(startTime, time.Now())
+			h.Next(c)
+		},
+		"response": func(c *context.Context, h context.Handler) {
+			log(c)
+			h.Next(c)
+		},
+		"error": func(c *context.Context, h context.Handler) {
+			log(c)
+			h.Next(c)
+		},
+	})
+	return p
+}
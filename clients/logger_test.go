@@ -0,0 +1,124 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type logEvent struct {
+	level  string
+	msg    string
+	fields Fields
+}
+
+// captureLogger records the events it receives
+type captureLogger struct {
+	sync.Mutex
+	events []logEvent
+}
+
+func (l *captureLogger) Debug(msg string, fields Fields) {
+	l.Lock()
+	defer l.Unlock()
+	l.events = append(l.events, logEvent{"debug", msg, fields})
+}
+
+func (l *captureLogger) Error(msg string, fields Fields) {
+	l.Lock()
+	defer l.Unlock()
+	l.events = append(l.events, logEvent{"error", msg, fields})
+}
+
+func TestLogRequests(t *testing.T) {
+	rec := &recorder{statuses: []int{503, 404}}
+	_, config := newRetryClient(t, rec, fastRetries())
+	logger := &captureLogger{}
+	config.Logger = logger
+
+	if err := send(t, config); err == nil {
+		t.Fatal("request answered with 404 succeeded")
+	}
+
+	if len(logger.events) != 1 {
+		t.Fatalf("got %d events, want 1 per request", len(logger.events))
+	}
+	e := logger.events[0]
+	if e.level != "debug" || e.msg != "Request to vbase" {
+		t.Errorf("got %s event %q, want a debug one", e.level, e.msg)
+	}
+	for name, want := range map[string]interface{}{
+		"service": "vbase",
+		"method":  http.MethodGet,
+		"path":    "/file",
+		"status":  404,
+		"retries": 1,
+	} {
+		if e.fields[name] != want {
+			t.Errorf("field %s is %v, want %v", name, e.fields[name], want)
+		}
+	}
+	if latency, ok := e.fields["latency"].(time.Duration); !ok || latency <= 0 {
+		t.Errorf("field latency is %v, want the request's", e.fields["latency"])
+	}
+	if err, _ := e.fields["error"].(string); !strings.Contains(err, "404") {
+		t.Errorf("field error is %q, want the response error", err)
+	}
+}
+
+func TestLogBrokenCallTrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderCallTrace, "not json")
+	}))
+	defer srv.Close()
+
+	incoming := httptest.NewRequest(http.MethodGet, "/", nil)
+	incoming.Header.Set("X-Vtex-Trace-Enable", "true")
+	config := testConfig(srv.URL)
+	config.RequestContext = NewRequestContext(incoming)
+	logger := &captureLogger{}
+	config.Logger = logger
+
+	if err := send(t, config); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range logger.events {
+		if e.level == "error" && e.fields["error"] != nil {
+			return
+		}
+	}
+	t.Errorf("got events %+v, want an error about the call trace", logger.events)
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	logger.Debug("debug message", Fields{"service": "vbase", "status": 200})
+	logger.Error("error message", Fields{"error": "broken"})
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, m)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	if l := lines[0]; l["level"] != "DEBUG" || l["msg"] != "debug message" || l["service"] != "vbase" || l["status"] != float64(200) {
+		t.Errorf("debug event was logged as %v", l)
+	}
+	if l := lines[1]; l["level"] != "ERROR" || l["msg"] != "error message" || l["error"] != "broken" {
+		t.Errorf("error event was logged as %v", l)
+	}
+}
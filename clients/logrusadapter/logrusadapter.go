@@ -0,0 +1,25 @@
+// Package logrusadapter adapts logrus loggers to clients.Logger, keeping the
+// clients package itself free of the logrus dependency.
+package logrusadapter
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/vtex/go-clients/clients"
+)
+
+// New adapts a logrus logger or entry to clients.Logger
+func New(l logrus.FieldLogger) clients.Logger {
+	return &logger{l}
+}
+
+type logger struct {
+	l logrus.FieldLogger
+}
+
+func (lg *logger) Debug(msg string, fields clients.Fields) {
+	lg.l.WithFields(logrus.Fields(fields)).Debug(msg)
+}
+
+func (lg *logger) Error(msg string, fields clients.Fields) {
+	lg.l.WithFields(logrus.Fields(fields)).Error(msg)
+}
@@ -0,0 +1,42 @@
+package logrusadapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vtex/go-clients/clients"
+)
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.Out = &buf
+	l.Formatter = &logrus.JSONFormatter{}
+	l.Level = logrus.DebugLevel
+
+	logger := New(l)
+	logger.Debug("debug message", clients.Fields{"service": "vbase", "status": 200})
+	logger.Error("error message", clients.Fields{"error": "broken"})
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, m)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	if l := lines[0]; l["level"] != "debug" || l["msg"] != "debug message" || l["service"] != "vbase" || l["status"] != float64(200) {
+		t.Errorf("debug event was logged as %v", l)
+	}
+	if l := lines[1]; l["level"] != "error" || l["msg"] != "error message" || l["error"] != "broken" {
+		t.Errorf("error event was logged as %v", l)
+	}
+}
@@ -0,0 +1,105 @@
+package clients
+
+import (
+	stdcontext "context"
+	"net/http"
+)
+
+type contextKey int
+
+const (
+	requestContextKey contextKey = iota
+	configKey
+)
+
+// Middleware returns a handler wrapper that gives each incoming request its
+// own RequestContext, and a Config for building clients on its behalf
+// authenticated with the request's credential. Both are stored in the
+// request's context, see RequestContextFrom and ConfigFrom. The headers
+// accumulated in the RequestContext are written to the response right before
+// its header is sent.
+//
+// The clients of a request without an X-Vtex-Credential header are not
+// authenticated: the credentials of config are never used on behalf of the
+// caller.
+//
+// The configs come from a Factory created from config, so that the clients
+// of every request share the same transport and setup.
+func Middleware(config *Config) func(http.Handler) http.Handler {
+	factory := NewFactory(config)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := NewRequestContext(r)
+			credential := GetCredential(r)
+			reqConfig := factory.Config(credential, reqCtx)
+			if credential == "" {
+				reqConfig.AuthToken = ""
+				reqConfig.AuthFunc = nil
+				reqConfig.TokenSource = nil
+			}
+
+			ctx := stdcontext.WithValue(r.Context(), requestContextKey, reqCtx)
+			ctx = stdcontext.WithValue(ctx, configKey, reqConfig)
+
+			rw := &responseWriter{ResponseWriter: w, reqCtx: reqCtx}
+			next.ServeHTTP(rw, r.WithContext(ctx))
+			rw.writeContext()
+		})
+	}
+}
+
+// RequestContextFrom returns the RequestContext stored by Middleware, or nil
+func RequestContextFrom(ctx stdcontext.Context) RequestContext {
+	reqCtx, _ := ctx.Value(requestContextKey).(RequestContext)
+	return reqCtx
+}
+
+// ConfigFrom returns a copy of the request-scoped Config stored by
+// Middleware, ready to be passed to any client constructor, or nil.
+func ConfigFrom(ctx stdcontext.Context) *Config {
+	config, ok := ctx.Value(configKey).(*Config)
+	if !ok {
+		return nil
+	}
+	configCopy := *config
+	return &configCopy
+}
+
+// responseWriter writes the headers of a RequestContext before the response
+// header is sent.
+type responseWriter struct {
+	http.ResponseWriter
+	reqCtx  RequestContext
+	written bool
+}
+
+func (w *responseWriter) writeContext() {
+	if !w.written {
+		w.written = true
+		w.reqCtx.Write(w.ResponseWriter)
+	}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.writeContext()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.writeContext()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the wrapper
+func (w *responseWriter) Flush() {
+	w.writeContext()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,80 @@
+package clients
+
+import (
+	stdcontext "context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareCredential(t *testing.T) {
+	base := testConfig("http://localhost")
+	base.AuthToken = "service-token"
+
+	var got *Config
+	handler := Middleware(base)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ConfigFrom(r.Context())
+		if RequestContextFrom(r.Context()) == nil {
+			t.Error("no RequestContext in the request's context")
+		}
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Vtex-Credential", "caller-token")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if got == nil || got.AuthToken != "caller-token" {
+		t.Errorf("got config %+v, want the caller's credential", got)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got == nil || got.AuthToken != "" || got.TokenSource != nil || got.AuthFunc != nil {
+		t.Errorf("got config %+v for an anonymous request, want no credential", got)
+	}
+}
+
+func TestMiddlewareWritesMeta(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Vtex-Meta", "upstream-meta")
+	}))
+	defer upstream.Close()
+
+	handler := Middleware(testConfig(upstream.URL))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := send(t, ConfigFrom(r.Context())); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("done"))
+	}))
+
+	res := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Vtex-Credential", "caller-token")
+	handler.ServeHTTP(res, r)
+
+	if res.Code != http.StatusAccepted || res.Body.String() != "done" {
+		t.Errorf("got %d %q, want the handler's response", res.Code, res.Body.String())
+	}
+	if meta := res.Header()["X-Vtex-Meta"]; len(meta) != 1 || meta[0] != "upstream-meta" {
+		t.Errorf("got X-Vtex-Meta %q, want the upstream's", meta)
+	}
+}
+
+func TestConfigFromOutsideMiddleware(t *testing.T) {
+	ctx := stdcontext.Background()
+	if config := ConfigFrom(ctx); config != nil {
+		t.Errorf("ConfigFrom outside the middleware returned %+v, want nil", config)
+	}
+	if reqCtx := RequestContextFrom(ctx); reqCtx != nil {
+		t.Errorf("RequestContextFrom outside the middleware returned %v, want nil", reqCtx)
+	}
+}
+
+func TestMiddlewareConfigIsCopied(t *testing.T) {
+	handler := Middleware(testConfig("http://localhost"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ConfigFrom(r.Context()).Workspace = "changed"
+		if ws := ConfigFrom(r.Context()).Workspace; ws != "master" {
+			t.Errorf("change to a config from ConfigFrom leaked: got workspace %q", ws)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,80 @@
+package clients
+
+import (
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// RequestInfo describes a finished request
+type RequestInfo struct {
+	Service string
+	Method  string
+	// StatusCode is zero when no response was received
+	StatusCode int
+	Duration   time.Duration
+	Retries    int
+	Err        error
+}
+
+// Observer is notified of every outbound request, e.g. to collect metrics.
+// Each RequestStarted is followed by exactly one RequestFinished, which may
+// be called from another goroutine.
+type Observer interface {
+	RequestStarted(service, method string)
+	RequestFinished(info RequestInfo)
+}
+
+// observeRequests reports requests as started before dial, once their method
+// is final. Requests that fail earlier, e.g. on an open circuit, are reported
+// as started just before finishing.
+func observeRequests(service string, observer Observer) plugin.Plugin {
+	const (
+		startTime = "observerStartTime"
+		started   = "observerStarted"
+	)
+
+	finish := func(c *context.Context) {
+		start, ok := c.GetOk(startTime)
+		if !ok {
+			return
+		}
+		c.Delete(startTime)
+
+		if _, ok := c.GetOk(started); !ok {
+			observer.RequestStarted(service, c.Request.Method)
+		}
+
+		observer.RequestFinished(RequestInfo{
+			Service:    service,
+			Method:     c.Request.Method,
+			StatusCode: c.Response.StatusCode,
+			Duration:   time.Since(start.(time.Time)),
+			Retries:    retries(c),
+			Err:        c.Error,
+		})
+	}
+
+	p := plugin.New()
+	p.SetHandlers(plugin.Handlers{
+		"request": func(c *context.Context, h context.Handler) {
+			c.Set(startTime, time.Now())
+			h.Next(c)
+		},
+		"before dial": func(c *context.Context, h context.Handler) {
+			c.Set(started, true)
+			observer.RequestStarted(service, c.Request.Method)
+			h.Next(c)
+		},
+		"response": func(c *context.Context, h context.Handler) {
+			finish(c)
+			h.Next(c)
+		},
+		"error": func(c *context.Context, h context.Handler) {
+			finish(c)
+			h.Next(c)
+		},
+	})
+	return p
+}
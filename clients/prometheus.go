@@ -0,0 +1,202 @@
+package clients
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets of a PrometheusCollector.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusCollector is an Observer that keeps request counts, error counts,
+// latency histograms and in-flight gauges per service. It serves them in the
+// Prometheus text format, so it can be mounted on a /metrics endpoint.
+type PrometheusCollector struct {
+	sync.Mutex
+	namespace string
+	buckets   []float64
+	requests  map[requestKey]uint64
+	errors    map[errorKey]uint64
+	latencies map[string]*histogram
+	inFlight  map[string]int64
+}
+
+type requestKey struct {
+	service, method, code string
+}
+
+type errorKey struct {
+	service, code string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewPrometheusCollector creates a collector whose metric names start with
+// namespace, e.g. "vtex_client".
+func NewPrometheusCollector(namespace string) *PrometheusCollector {
+	return &PrometheusCollector{
+		namespace: namespace,
+		buckets:   DefaultLatencyBuckets,
+		requests:  map[requestKey]uint64{},
+		errors:    map[errorKey]uint64{},
+		latencies: map[string]*histogram{},
+		inFlight:  map[string]int64{},
+	}
+}
+
+func (p *PrometheusCollector) RequestStarted(service, method string) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.inFlight[service]++
+}
+
+func (p *PrometheusCollector) RequestFinished(info RequestInfo) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.inFlight[info.Service]--
+
+	code := "none"
+	if info.StatusCode != 0 {
+		code = strconv.Itoa(info.StatusCode)
+	}
+	p.requests[requestKey{info.Service, info.Method, code}]++
+	if info.Err != nil {
+		p.errors[errorKey{info.Service, code}]++
+	}
+
+	h, ok := p.latencies[info.Service]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		p.latencies[info.Service] = h
+	}
+	secs := info.Duration.Seconds()
+	for i, bound := range p.buckets {
+		if secs <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+// ServeHTTP writes the collected metrics in the Prometheus text format
+func (p *PrometheusCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the collected metrics in the Prometheus text format
+func (p *PrometheusCollector) WriteTo(w io.Writer) (int64, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	mw := &metricsWriter{w: w}
+
+	name := p.namespace + "_requests_total"
+	mw.header(name, "counter", "Requests sent, by service, method and status code.")
+	requests := make([]requestKey, 0, len(p.requests))
+	for k := range p.requests {
+		requests = append(requests, k)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.service != b.service {
+			return a.service < b.service
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	for _, k := range requests {
+		mw.printf("%s{service=%s,method=%s,code=%s} %d\n", name, labelValue(k.service), labelValue(k.method), labelValue(k.code), p.requests[k])
+	}
+
+	name = p.namespace + "_request_errors_total"
+	mw.header(name, "counter", "Failed requests, by service and status code.")
+	errs := make([]errorKey, 0, len(p.errors))
+	for k := range p.errors {
+		errs = append(errs, k)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].service != errs[j].service {
+			return errs[i].service < errs[j].service
+		}
+		return errs[i].code < errs[j].code
+	})
+	for _, k := range errs {
+		mw.printf("%s{service=%s,code=%s} %d\n", name, labelValue(k.service), labelValue(k.code), p.errors[k])
+	}
+
+	name = p.namespace + "_request_duration_seconds"
+	mw.header(name, "histogram", "Request latency, retries included, by service.")
+	services := make([]string, 0, len(p.latencies))
+	for service := range p.latencies {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		h, label := p.latencies[service], labelValue(service)
+		for i, bound := range p.buckets {
+			mw.printf("%s_bucket{service=%s,le=\"%s\"} %d\n", name, label, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		mw.printf("%s_bucket{service=%s,le=\"+Inf\"} %d\n", name, label, h.count)
+		mw.printf("%s_sum{service=%s} %g\n", name, label, h.sum)
+		mw.printf("%s_count{service=%s} %d\n", name, label, h.count)
+	}
+
+	name = p.namespace + "_requests_in_flight"
+	mw.header(name, "gauge", "Requests currently being sent, by service.")
+	services = services[:0]
+	for service := range p.inFlight {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		mw.printf("%s{service=%s} %d\n", name, labelValue(service), p.inFlight[service])
+	}
+
+	return mw.n, mw.err
+}
+
+// labelEscaper escapes label values as the Prometheus text format requires:
+// only backslashes, double quotes and newlines, any other character as is
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue quotes a label value for the Prometheus text format
+func labelValue(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+// metricsWriter keeps the first write error, so the output can be written
+// without checking every line.
+type metricsWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (mw *metricsWriter) printf(format string, args ...interface{}) {
+	if mw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(mw.w, format, args...)
+	mw.n += int64(n)
+	mw.err = err
+}
+
+func (mw *metricsWriter) header(name, kind, help string) {
+	mw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
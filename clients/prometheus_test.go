@@ -0,0 +1,91 @@
+package clients
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func metrics(t *testing.T, p *PrometheusCollector) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func checkLines(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	rec := &recorder{statuses: []int{200, 404}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	p := NewPrometheusCollector("test")
+	config := testConfig(srv.URL)
+	config.Observer = p
+	send(t, config)
+	send(t, config)
+
+	out := metrics(t, p)
+	checkLines(t, out,
+		`test_requests_total{service="vbase",method="GET",code="200"} 1`,
+		`test_requests_total{service="vbase",method="GET",code="404"} 1`,
+		`test_request_errors_total{service="vbase",code="404"} 1`,
+		`test_request_duration_seconds_bucket{service="vbase",le="+Inf"} 2`,
+		`test_request_duration_seconds_count{service="vbase"} 2`,
+		`test_requests_in_flight{service="vbase"} 0`,
+	)
+	if strings.Contains(out, `test_request_errors_total{service="vbase",code="200"}`) {
+		t.Errorf("successful request was counted as an error:\n%s", out)
+	}
+
+	// Buckets are cumulative
+	last := uint64(0)
+	for _, m := range regexp.MustCompile(`_bucket\{service="vbase",le="[^"]+"\} (\d+)`).FindAllStringSubmatch(out, -1) {
+		n, _ := strconv.ParseUint(m[1], 10, 64)
+		if n < last {
+			t.Errorf("bucket counts decrease:\n%s", out)
+		}
+		last = n
+	}
+}
+
+func TestPrometheusHistogram(t *testing.T) {
+	p := NewPrometheusCollector("test")
+	for _, d := range []time.Duration{3 * time.Millisecond, 30 * time.Millisecond} {
+		p.RequestStarted("vbase", http.MethodGet)
+		p.RequestFinished(RequestInfo{Service: "vbase", Method: http.MethodGet, StatusCode: 200, Duration: d})
+	}
+	p.RequestStarted("vbase", http.MethodGet)
+
+	checkLines(t, metrics(t, p),
+		`test_request_duration_seconds_bucket{service="vbase",le="0.005"} 1`,
+		`test_request_duration_seconds_bucket{service="vbase",le="0.025"} 1`,
+		`test_request_duration_seconds_bucket{service="vbase",le="0.05"} 2`,
+		`test_request_duration_seconds_bucket{service="vbase",le="10"} 2`,
+		`test_request_duration_seconds_sum{service="vbase"} 0.033`,
+		`test_request_duration_seconds_count{service="vbase"} 2`,
+		`test_requests_in_flight{service="vbase"} 1`,
+	)
+}
+
+func TestPrometheusLabelEscaping(t *testing.T) {
+	p := NewPrometheusCollector("test")
+	p.RequestStarted("sérvice \"a\\b\"\n", http.MethodGet)
+
+	checkLines(t, metrics(t, p), `test_requests_in_flight{service="sérvice \"a\\b\"\n"} 1`)
+}
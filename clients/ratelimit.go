@@ -0,0 +1,203 @@
+package clients
+
+import (
+	stdcontext "context"
+	"net/http"
+	"sync"
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// RateLimit bounds the requests a process sends to one service. Requests
+// over the limit wait for their turn, within their timeout.
+//
+// A 429 response halves the rate and, when it tells a Retry-After, holds
+// every request back until then. Each following successful request gives
+// back a twentieth of the configured rate.
+type RateLimit struct {
+	// RequestsPerSecond is the sustained rate. Zero leaves the rate unlimited,
+	// though 429 responses still hold requests back.
+	RequestsPerSecond float64
+	// Burst is how many requests may be sent at once after being idle, one
+	// by default
+	Burst int
+	// MaxInFlight is how many requests may be awaiting their response at the
+	// same time. Zero leaves it unlimited.
+	MaxInFlight int
+}
+
+const (
+	// minRateFraction is how far 429 responses may lower the rate
+	minRateFraction = 1.0 / 16
+	// rateRecovery is the fraction of the rate recovered per success
+	rateRecovery = 1.0 / 20
+)
+
+// Limiters are shared by every client of the same endpoint with the same
+// limit, so that request-scoped clients draw from the same budget while
+// clients configured differently keep their own. Requests failed over to
+// another region draw from the budget of that region's endpoint.
+var limiters = struct {
+	sync.Mutex
+	m map[limiterKey]*limiter
+}{m: map[limiterKey]*limiter{}}
+
+type limiterKey struct {
+	endpoint string
+	limit    RateLimit
+}
+
+func limiterFor(endpoint string, limit *RateLimit) *limiter {
+	limiters.Lock()
+	defer limiters.Unlock()
+
+	key := limiterKey{endpoint, *limit}
+	l, ok := limiters.m[key]
+	if !ok {
+		l = newLimiter(limit)
+		limiters.m[key] = l
+	}
+	return l
+}
+
+type limiter struct {
+	sync.Mutex
+	limit       RateLimit
+	rate        float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	inFlight    chan struct{}
+}
+
+func newLimiter(limit *RateLimit) *limiter {
+	l := &limiter{limit: *limit, last: time.Now()}
+	if l.limit.Burst < 1 {
+		l.limit.Burst = 1
+	}
+	l.rate = l.limit.RequestsPerSecond
+	l.tokens = float64(l.limit.Burst)
+	if l.limit.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, l.limit.MaxInFlight)
+	}
+	return l
+}
+
+// acquire waits until a request may be sent. Every successful acquire must be
+// followed by a call to release.
+func (l *limiter) acquire(ctx stdcontext.Context) error {
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for {
+		wait := l.reserve()
+		if wait <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			l.release()
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reserve takes a token if one is available, or returns how long to wait
+// for the next one.
+func (l *limiter) reserve() time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.limit.Burst) {
+		l.tokens = float64(l.limit.Burst)
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *limiter) release() {
+	if l.inFlight != nil {
+		<-l.inFlight
+	}
+}
+
+// adapt lowers the rate on throttling and recovers it on success
+func (l *limiter) adapt(res *http.Response) {
+	l.Lock()
+	defer l.Unlock()
+
+	if res.StatusCode != http.StatusTooManyRequests {
+		if l.rate < l.limit.RequestsPerSecond {
+			l.rate += l.limit.RequestsPerSecond * rateRecovery
+			if l.rate > l.limit.RequestsPerSecond {
+				l.rate = l.limit.RequestsPerSecond
+			}
+		}
+		return
+	}
+
+	if after, ok := retryAfter(res.Header); ok {
+		if until := time.Now().Add(after); until.After(l.pausedUntil) {
+			l.pausedUntil = until
+		}
+	}
+	if min := l.limit.RequestsPerSecond * minRateFraction; l.rate/2 > min {
+		l.rate /= 2
+	} else {
+		l.rate = min
+	}
+}
+
+// limitRequests wraps the transport of each request in a limitTransport
+func limitRequests(limit *RateLimit) plugin.Plugin {
+	return plugin.NewPhasePlugin("before dial", func(c *context.Context, h context.Handler) {
+		c.Client.Transport = &limitTransport{next: c.Client.Transport, limit: limit}
+		h.Next(c)
+	})
+}
+
+// limitTransport holds every attempt of a request back until the limiter of
+// the endpoint it is sent to lets it through. The in-flight slot is given
+// back as soon as the response headers arrive, so that unread bodies never
+// hold it.
+type limitTransport struct {
+	next  http.RoundTripper
+	limit *RateLimit
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := limiterFor(dialedEndpoint(req.URL), t.limit)
+	if err := l.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	defer l.release()
+
+	res, err := t.next.RoundTrip(req)
+	if err == nil {
+		l.adapt(res)
+	}
+	return res, err
+}
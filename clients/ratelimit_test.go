@@ -0,0 +1,115 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	srv := httptest.NewServer(&recorder{})
+	defer srv.Close()
+
+	config := testConfig(srv.URL)
+	config.RateLimits = map[string]*RateLimit{"vbase": {RequestsPerSecond: 20, Burst: 2}}
+
+	cl, _ := NewClient("vbase", config, false)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := cl.Get().AddPath("/file").Send(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The burst goes right away, the other 4 requests wait 50ms each
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond || elapsed > time.Second {
+		t.Errorf("6 requests took %v, want about 200ms at 20 per second with a burst of 2", elapsed)
+	}
+}
+
+func TestRateLimitInFlight(t *testing.T) {
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer srv.Close()
+
+	config := testConfig(srv.URL)
+	config.RateLimits = map[string]*RateLimit{"vbase": {MaxInFlight: 2}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cl, _ := NewClient("vbase", config, false)
+			cl.Get().AddPath("/file").Send()
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight != 2 {
+		t.Errorf("got %d requests in flight at most, want 2", maxInFlight)
+	}
+}
+
+func TestRateLimitAdapts(t *testing.T) {
+	l := newLimiter(&RateLimit{RequestsPerSecond: 16})
+	throttled := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	ok := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+
+	for _, want := range []float64{8, 4, 2, 1, 1} {
+		l.adapt(throttled)
+		if l.rate != want {
+			t.Fatalf("rate after a 429 is %v, want %v", l.rate, want)
+		}
+	}
+
+	for _, want := range []float64{1.8, 2.6} {
+		l.adapt(ok)
+		if diff := l.rate - want; diff > 1e-9 || diff < -1e-9 {
+			t.Fatalf("rate after a success is %v, want %v", l.rate, want)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		l.adapt(ok)
+	}
+	if l.rate != 16 {
+		t.Errorf("rate after recovering is %v, want the configured 16", l.rate)
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	l := newLimiter(&RateLimit{})
+	l.adapt(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}})
+
+	if wait := l.reserve(); wait < 900*time.Millisecond || wait > time.Second {
+		t.Errorf("wait after Retry-After: 1 is %v, want about 1s even without a rate", wait)
+	}
+}
+
+func TestRateLimitScope(t *testing.T) {
+	limit := &RateLimit{RequestsPerSecond: 10}
+	same := limiterFor("http://vbase.example.com", limit)
+
+	if limiterFor("http://vbase.example.com", &RateLimit{RequestsPerSecond: 10}) != same {
+		t.Error("clients of the same endpoint and limit got different limiters")
+	}
+	if limiterFor("http://other.example.com", limit) == same {
+		t.Error("clients of another endpoint got the same limiter")
+	}
+	if limiterFor("http://vbase.example.com", &RateLimit{RequestsPerSecond: 100}) == same {
+		t.Error("clients with another limit got the same limiter")
+	}
+}
@@ -8,22 +8,30 @@ import (
 const (
 	metadataHeader    = "X-Vtex-Meta"
 	enableTraceHeader = "X-Vtex-Trace-Enable"
-	traceHeader       = "X-Call-Trace"
+	traceHeader       = HeaderCallTrace
 )
 
+// HeaderCallTrace accumulates the JSON array of CallTree of the calls made on
+// behalf of a request, when tracing was enabled by the caller
+const HeaderCallTrace = "X-Call-Trace"
+
 type RequestContext interface {
 	Parse(h http.Header)
 	Write(w http.ResponseWriter)
 	UpdateS(header string, update func(current string) string)
+	GetS(header string) string
 	isTraceEnabled() bool
+	traceParent() *traceParent
 }
 
 func NewRequestContext(parent *http.Request) RequestContext {
 	headers := map[string][]string{}
 	enableTrace := false
+	trace := parseTraceParent(http.Header{})
 
 	if parent != nil {
 		enableTrace = parent.Header.Get(enableTraceHeader) == "true"
+		trace = parseTraceParent(parent.Header)
 
 		if enableTrace {
 			headers[enableTraceHeader] = []string{"true"}
@@ -33,6 +41,7 @@ func NewRequestContext(parent *http.Request) RequestContext {
 	return &requestContext{
 		headers:           headers,
 		enableTraceHeader: enableTrace,
+		trace:             trace,
 	}
 }
 
@@ -40,6 +49,7 @@ type requestContext struct {
 	sync.RWMutex
 	headers           http.Header
 	enableTraceHeader bool
+	trace             *traceParent
 }
 
 // Parse parses an incoming response in order to accumulate headers
@@ -71,6 +81,18 @@ func (c *requestContext) UpdateS(header string, update func(current string) stri
 	c.headers.Set(header, update(c.headers.Get(header)))
 }
 
+// GetS returns the current value of a header for the outgoing response
+func (c *requestContext) GetS(header string) string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.headers.Get(header)
+}
+
 func (c *requestContext) isTraceEnabled() bool {
 	return c.enableTraceHeader
 }
+
+func (c *requestContext) traceParent() *traceParent {
+	return c.trace
+}
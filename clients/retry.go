@@ -0,0 +1,347 @@
+package clients
+
+import (
+	"bytes"
+	stdcontext "context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// RetryPolicy describes when and how failed requests are sent again. Zero
+// fields take the values of DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, the first one included
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. Each following wait
+	// is Multiplier times the previous one, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomly shortens each wait by up to this fraction of it
+	Jitter float64
+	// StatusCodes are the response statuses worth retrying
+	StatusCodes []int
+	// RetryError reports whether a transport error is worth retrying
+	RetryError func(err error) bool
+	// Idempotent reports whether a request can safely be sent more than once
+	Idempotent func(req *http.Request) bool
+	// MaxBodySize is the largest request body buffered to be sent again.
+	// Requests with a larger body are sent once, unless their GetBody can
+	// rewind it.
+	MaxBodySize int64
+}
+
+// DefaultRetryPolicy retries idempotent requests up to three times on
+// gateway errors, throttling and transient network failures.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		StatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryError:  IsTransientError,
+		Idempotent:  IsIdempotent,
+		MaxBodySize: defaultMaxBodySize,
+	}
+}
+
+// IsIdempotent reports whether req uses a method that may be repeated without
+// side effects. POST and PATCH are not.
+func IsIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// IsTransientError reports whether err is a network failure that may not
+// happen again, such as a timeout or a connection reset. The caller's own
+// cancellation or deadline is never transient.
+func IsTransientError(err error) bool {
+	if errors.Is(err, stdcontext.Canceled) || errors.Is(err, stdcontext.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (p *RetryPolicy) withDefaults() *RetryPolicy {
+	def := DefaultRetryPolicy()
+	policy := *p
+
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = def.MaxAttempts
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = def.InitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = def.MaxBackoff
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = def.Multiplier
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		policy.Jitter = def.Jitter
+	}
+	if policy.StatusCodes == nil {
+		policy.StatusCodes = def.StatusCodes
+	}
+	if policy.RetryError == nil {
+		policy.RetryError = def.RetryError
+	}
+	if policy.Idempotent == nil {
+		policy.Idempotent = def.Idempotent
+	}
+	if policy.MaxBodySize <= 0 {
+		policy.MaxBodySize = def.MaxBodySize
+	}
+
+	return &policy
+}
+
+func (p *RetryPolicy) retryStatus(status int) bool {
+	for _, s := range p.StatusCodes {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the given retry, counting from 1
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	d -= d * p.Jitter * rand.Float64()
+	return time.Duration(d)
+}
+
+const retryTransportKey = "retryTransport"
+
+// retryRequests wraps the transport of each request in a retryTransport. It
+// runs before dial so it wraps whatever transport the request ended up with.
+func retryRequests(policy *RetryPolicy) plugin.Plugin {
+	return plugin.NewPhasePlugin("before dial", func(c *context.Context, h context.Handler) {
+		rt := &retryTransport{next: c.Client.Transport, policy: policy}
+		c.Client.Transport = rt
+		c.Set(retryTransportKey, rt)
+		h.Next(c)
+	})
+}
+
+// retries returns how many times the request of c was retried
+func retries(c *context.Context) int {
+	if rt, ok := c.GetOk(retryTransportKey); ok {
+		return rt.(*retryTransport).retries
+	}
+	return 0
+}
+
+type retryTransport struct {
+	next    http.RoundTripper
+	policy  *RetryPolicy
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.policy.Idempotent(req) || t.policy.MaxAttempts < 2 {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := replayBody(req, t.policy.MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		outgoing, err := body.rewind(req)
+		if err != nil {
+			return nil, err
+		}
+		res, err := t.next.RoundTrip(outgoing)
+		if attempt >= t.policy.MaxAttempts || !body.replayable {
+			return res, err
+		}
+
+		var wait time.Duration
+		if err != nil {
+			if !t.policy.RetryError(err) {
+				return res, err
+			}
+			wait = t.policy.backoff(attempt)
+		} else {
+			if !t.policy.retryStatus(res.StatusCode) {
+				return res, err
+			}
+			wait = t.policy.backoff(attempt)
+			if after, ok := retryAfter(res.Header); ok {
+				wait = after
+			}
+		}
+
+		ctx := req.Context()
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			// Waiting would outlive the request anyway, so report what we have.
+			return res, err
+		}
+
+		if res != nil {
+			drain(res)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		t.retries++
+	}
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an
+// HTTP date.
+func retryAfter(h http.Header) (time.Duration, bool) {
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(time.Now()); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// defaultMaxBodySize is the largest request body buffered in memory so that
+// it can be sent again
+const defaultMaxBodySize = 1 << 20
+
+// replayableBody sends a request body again from its start. Bodies too large
+// to be kept in memory can only be sent once, which replayable reports.
+type replayableBody struct {
+	getBody    func() (io.ReadCloser, error)
+	size       int64
+	replayable bool
+}
+
+// replayBody prepares the body of req to be sent more than once. Bodies
+// without GetBody are buffered in memory, unless they are larger than
+// maxSize.
+func replayBody(req *http.Request, maxSize int64) (*replayableBody, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return &replayableBody{replayable: true}, nil
+	}
+	if req.GetBody != nil {
+		return &replayableBody{req.GetBody, req.ContentLength, true}, nil
+	}
+	if req.ContentLength > maxSize {
+		return sendOnce(req.Body, req.ContentLength), nil
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, maxSize+1))
+	if err != nil {
+		req.Body.Close()
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		// Larger than announced: send what was read followed by the rest
+		rest := struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+		return sendOnce(rest, req.ContentLength), nil
+	}
+	req.Body.Close()
+
+	if len(data) == 0 {
+		return &replayableBody{replayable: true}, nil
+	}
+	return &replayableBody{
+		getBody: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		},
+		size:       int64(len(data)),
+		replayable: true,
+	}, nil
+}
+
+func sendOnce(body io.ReadCloser, size int64) *replayableBody {
+	sent := false
+	return &replayableBody{
+		getBody: func() (io.ReadCloser, error) {
+			if sent {
+				return nil, errors.New("request body can't be sent twice")
+			}
+			sent = true
+			return body, nil
+		},
+		size: size,
+	}
+}
+
+// rewind returns a copy of req that sends the body from its start
+func (b *replayableBody) rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if b.getBody == nil {
+		clone.Body = http.NoBody
+		clone.GetBody = nil
+		clone.ContentLength = 0
+		return clone, nil
+	}
+
+	body, err := b.getBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	clone.ContentLength = b.size
+	clone.GetBody = nil
+	if b.replayable {
+		clone.GetBody = b.getBody
+	}
+	return clone, nil
+}
+
+// drain discards the rest of a response body so the connection can be reused
+func drain(res *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4<<10))
+	res.Body.Close()
+}
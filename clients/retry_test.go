@@ -0,0 +1,232 @@
+package clients
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testConfig returns a valid config for clients of the server at endpoint
+func testConfig(endpoint string) *Config {
+	return &Config{
+		Account:        "account",
+		Workspace:      "master",
+		Endpoint:       endpoint,
+		AuthToken:      "token",
+		RequestContext: NewRequestContext(nil),
+	}
+}
+
+// recorder is a handler that answers with the next of its statuses, repeating
+// the last one, and records the requests it gets.
+type recorder struct {
+	sync.Mutex
+	statuses []int
+	header   http.Header
+	requests []*recordedRequest
+}
+
+type recordedRequest struct {
+	method string
+	header http.Header
+	body   string
+	at     time.Time
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	rec.Lock()
+	rec.requests = append(rec.requests, &recordedRequest{r.Method, r.Header, string(body), time.Now()})
+	status := http.StatusOK
+	if len(rec.statuses) > 0 {
+		status = rec.statuses[0]
+		if len(rec.statuses) > 1 {
+			rec.statuses = rec.statuses[1:]
+		}
+	}
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+	rec.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func (rec *recorder) count() int {
+	rec.Lock()
+	defer rec.Unlock()
+	return len(rec.requests)
+}
+
+func newRetryClient(t *testing.T, rec *recorder, policy *RetryPolicy) (*httptest.Server, *Config) {
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+
+	config := testConfig(srv.URL)
+	config.RetryPolicy = policy
+	return srv, config
+}
+
+func fastRetries() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := (&RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     3,
+	}).withDefaults()
+	policy.Jitter = 0
+
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := policy.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(1); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("backoff(1) with jitter = %v, want within [50ms, 100ms]", got)
+		}
+	}
+}
+
+func TestRetryStatus(t *testing.T) {
+	rec := &recorder{statuses: []int{503, 502, 200}}
+	_, config := newRetryClient(t, rec, fastRetries())
+
+	cl, err := NewClient("vbase", config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := cl.Get().AddPath("/file").Send()
+	if err != nil {
+		t.Fatalf("Get failed after retries: %v", err)
+	}
+	if res.StatusCode != 200 || rec.count() != 3 {
+		t.Errorf("got status %d after %d requests, want 200 after 3", res.StatusCode, rec.count())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	rec := &recorder{statuses: []int{503}}
+	_, config := newRetryClient(t, rec, fastRetries())
+
+	cl, _ := NewClient("vbase", config, false)
+	_, err := cl.Get().AddPath("/file").Send()
+	if !errors.Is(err, ErrServerError) {
+		t.Errorf("got error %v, want ErrServerError", err)
+	}
+	if rec.count() != 3 {
+		t.Errorf("got %d requests, want 3", rec.count())
+	}
+}
+
+func TestRetryIdempotency(t *testing.T) {
+	for method, want := range map[string]int{
+		http.MethodGet:    3,
+		http.MethodPut:    3,
+		http.MethodDelete: 3,
+		http.MethodPost:   1,
+		http.MethodPatch:  1,
+	} {
+		rec := &recorder{statuses: []int{503}}
+		_, config := newRetryClient(t, rec, fastRetries())
+
+		cl, _ := NewClient("vbase", config, false)
+		cl.Request().Method(method).AddPath("/file").BodyString("content").Send()
+		if rec.count() != want {
+			t.Errorf("%s was sent %d times, want %d", method, rec.count(), want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	rec := &recorder{statuses: []int{429, 200}, header: http.Header{"Retry-After": {"1"}}}
+	_, config := newRetryClient(t, rec, fastRetries())
+
+	cl, _ := NewClient("vbase", config, false)
+	if _, err := cl.Get().AddPath("/file").Send(); err != nil {
+		t.Fatal(err)
+	}
+	if rec.count() != 2 {
+		t.Fatalf("got %d requests, want 2", rec.count())
+	}
+	if wait := rec.requests[1].at.Sub(rec.requests[0].at); wait < time.Second {
+		t.Errorf("retried after %v, want at least the 1s of Retry-After", wait)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if wait, ok := retryAfter(http.Header{"Retry-After": {date}}); !ok || wait < 59*time.Minute {
+		t.Errorf("retryAfter(%q) = %v, %v, want about an hour", date, wait, ok)
+	}
+	if _, ok := retryAfter(http.Header{"Retry-After": {"soon"}}); ok {
+		t.Error("retryAfter accepted an invalid value")
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	rec := &recorder{statuses: []int{503}, header: http.Header{"Retry-After": {"30"}}}
+	_, config := newRetryClient(t, rec, fastRetries())
+	config.Timeout = 500 * time.Millisecond
+
+	cl, _ := NewClient("vbase", config, false)
+	start := time.Now()
+	_, err := cl.Get().AddPath("/file").Send()
+	if !errors.Is(err, ErrServerError) {
+		t.Errorf("got error %v, want the last response as ErrServerError", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("gave up after %v, want right away as the wait outlives the timeout", elapsed)
+	}
+	if rec.count() != 1 {
+		t.Errorf("got %d requests, want 1", rec.count())
+	}
+}
+
+func TestRetryBody(t *testing.T) {
+	for name, body := range map[string]io.Reader{
+		"of known size":   strings.NewReader("content"),
+		"of unknown size": ioutil.NopCloser(strings.NewReader("content")),
+	} {
+		rec := &recorder{statuses: []int{503, 200}}
+		_, config := newRetryClient(t, rec, fastRetries())
+
+		cl, _ := NewClient("vbase", config, false)
+		if _, err := cl.Put().AddPath("/file").Body(body).Send(); err != nil {
+			t.Fatal(err)
+		}
+		if rec.count() != 2 || rec.requests[0].body != "content" || rec.requests[1].body != "content" {
+			t.Errorf("a body %s was sent %d times, want twice in whole", name, rec.count())
+		}
+	}
+}
+
+func TestRetrySkipsLargeBodies(t *testing.T) {
+	policy := fastRetries()
+	policy.MaxBodySize = 4
+
+	for name, body := range map[string]io.Reader{
+		"above the limit": strings.NewReader("content"),
+		"of unknown size": ioutil.NopCloser(strings.NewReader("content")),
+	} {
+		rec := &recorder{statuses: []int{503}}
+		_, config := newRetryClient(t, rec, policy)
+
+		cl, _ := NewClient("vbase", config, false)
+		cl.Put().AddPath("/file").Body(body).Send()
+		if rec.count() != 1 {
+			t.Errorf("a body %s was sent %d times, want 1", name, rec.count())
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// tokenExpiryLeeway is how long before its expiry a token is refreshed, so
+// that it doesn't expire on its way to the service
+const tokenExpiryLeeway = 10 * time.Second
+
+// Token is a credential for the Authorization header
+type Token struct {
+	Value string
+	// Expiry is when the token stops being valid, or zero if unknown
+	Expiry time.Time
+}
+
+func (t *Token) valid() bool {
+	if t == nil || t.Value == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(tokenExpiryLeeway).Before(t.Expiry)
+}
+
+// TokenSource provides the tokens requests are authenticated with. A request
+// answered with 401 is sent once more with a new token, if the source gives
+// a different one.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource
+type TokenSourceFunc func() (*Token, error)
+
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+// tokenInvalidator is implemented by sources that can drop a token rejected
+// by a service, so that the next call to Token gets a new one.
+type tokenInvalidator interface {
+	invalidate(token *Token)
+}
+
+// StaticTokenSource always returns the same token
+func StaticTokenSource(token string) TokenSource {
+	return TokenSourceFunc(func() (*Token, error) {
+		return &Token{Value: token}, nil
+	})
+}
+
+// CredentialTokenSource returns the credential of an incoming request, as
+// sent in its X-Vtex-Credential header.
+func CredentialTokenSource(r *http.Request) TokenSource {
+	return StaticTokenSource(GetCredential(r))
+}
+
+// NewCachedTokenSource reuses the tokens of src until they are about to
+// expire or get rejected. Concurrent callers wait for a single refresh
+// instead of each asking src for a token.
+func NewCachedTokenSource(src TokenSource) TokenSource {
+	return &cachedTokenSource{src: src}
+}
+
+type cachedTokenSource struct {
+	sync.Mutex
+	src     TokenSource
+	token   *Token
+	refresh *tokenRefresh
+}
+
+type tokenRefresh struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+func (s *cachedTokenSource) Token() (*Token, error) {
+	s.Lock()
+	if s.token.valid() {
+		defer s.Unlock()
+		return s.token, nil
+	}
+
+	if r := s.refresh; r != nil {
+		s.Unlock()
+		<-r.done
+		return r.token, r.err
+	}
+
+	r := &tokenRefresh{done: make(chan struct{})}
+	s.refresh = r
+	s.Unlock()
+
+	r.token, r.err = s.src.Token()
+
+	s.Lock()
+	s.refresh = nil
+	if r.err == nil {
+		s.token = r.token
+	}
+	s.Unlock()
+	close(r.done)
+
+	return r.token, r.err
+}
+
+func (s *cachedTokenSource) invalidate(token *Token) {
+	s.Lock()
+	if s.token != nil && s.token.Value == token.Value {
+		s.token = nil
+	}
+	s.Unlock()
+
+	if inv, ok := s.src.(tokenInvalidator); ok {
+		inv.invalidate(token)
+	}
+}
+
+// FileTokenSource reads the token from a file, reloading it whenever the
+// file changes, e.g. when a secret mounted by the orchestrator is rotated.
+func FileTokenSource(path string) TokenSource {
+	return &fileTokenSource{path: path}
+}
+
+type fileTokenSource struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	token   *Token
+}
+
+func (s *fileTokenSource) Token() (*Token, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading token file: %v", err)
+	}
+	if s.token != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.token, nil
+	}
+
+	buf, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading token file: %v", err)
+	}
+	value := strings.TrimSpace(string(buf))
+	if value == "" {
+		return nil, fmt.Errorf("Error reading token file: %s is empty", s.path)
+	}
+
+	s.token = &Token{Value: value}
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	return s.token, nil
+}
+
+func (s *fileTokenSource) invalidate(token *Token) {
+	s.Lock()
+	defer s.Unlock()
+	if s.token != nil && s.token.Value == token.Value {
+		s.token = nil
+	}
+}
+
+// authenticate wraps the transport of each request in an authTransport
+func authenticate(source TokenSource) plugin.Plugin {
+	return plugin.NewPhasePlugin("before dial", func(c *context.Context, h context.Handler) {
+		c.Client.Transport = &authTransport{next: c.Client.Transport, source: source}
+		h.Next(c)
+	})
+}
+
+// authTransport sets the Authorization header of each request from a
+// TokenSource. A request answered with 401 was not processed, so whatever its
+// method it is sent again once with a new token, unless its body is too large
+// to be kept for that.
+type authTransport struct {
+	next   http.RoundTripper
+	source TokenSource
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.token()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := replayBody(req, defaultMaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := body.rewind(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.next.RoundTrip(authorize(first, token))
+	if err != nil || res.StatusCode != http.StatusUnauthorized || !body.replayable {
+		return res, err
+	}
+
+	if inv, ok := t.source.(tokenInvalidator); ok {
+		inv.invalidate(token)
+	}
+
+	fresh, tokenErr := t.token()
+	if tokenErr != nil || fresh.Value == token.Value {
+		return res, err
+	}
+
+	retry, rewindErr := body.rewind(req)
+	if rewindErr != nil {
+		return res, err
+	}
+	drain(res)
+	return t.next.RoundTrip(authorize(retry, fresh))
+}
+
+func (t *authTransport) token() (*Token, error) {
+	token, err := t.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting auth token: %v", err)
+	}
+	if token == nil || token.Value == "" {
+		return nil, errors.New("Error getting auth token: empty token")
+	}
+	return token, nil
+}
+
+// authorize sets the Authorization header of a copy of the request
+func authorize(req *http.Request, token *Token) *http.Request {
+	req.Header.Set("Authorization", "Bearer "+token.Value)
+	return req
+}
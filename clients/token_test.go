@@ -0,0 +1,141 @@
+package clients
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingSource returns a new token on every call, valid for ttl
+func countingSource(calls *int32, ttl time.Duration) TokenSource {
+	return TokenSourceFunc(func() (*Token, error) {
+		n := atomic.AddInt32(calls, 1)
+		return &Token{Value: "token-" + strconv.Itoa(int(n)), Expiry: time.Now().Add(ttl)}, nil
+	})
+}
+
+func TestCachedTokenSourceSingleFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	src := NewCachedTokenSource(TokenSourceFunc(func() (*Token, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &Token{Value: "token"}, nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := src.Token(); err != nil || token.Value != "token" {
+				t.Errorf("got %v, %v, want the refreshed token", token, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("source was called %d times, want once for every concurrent caller", calls)
+	}
+}
+
+func TestCachedTokenSourceExpiry(t *testing.T) {
+	var calls int32
+	src := NewCachedTokenSource(countingSource(&calls, time.Minute))
+	first, _ := src.Token()
+	if second, _ := src.Token(); second != first || calls != 1 {
+		t.Errorf("got %s after %d calls, want the cached %s", second.Value, calls, first.Value)
+	}
+
+	calls = 0
+	src = NewCachedTokenSource(countingSource(&calls, tokenExpiryLeeway/2))
+	first, _ = src.Token()
+	if second, _ := src.Token(); second == first || calls != 2 {
+		t.Errorf("got %s after %d calls, want a new token as %s expires within the leeway", second.Value, calls, first.Value)
+	}
+}
+
+func TestFileTokenSourceRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	src := FileTokenSource(path)
+	write := func(value string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(value+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(want string) {
+		t.Helper()
+		if token, err := src.Token(); err != nil || token.Value != want {
+			t.Errorf("got %v, %v, want %s", token, err, want)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write("first", start)
+	expect("first")
+
+	// Same modification time, different size
+	write("second-token", start)
+	expect("second-token")
+
+	// Same size, different modification time
+	write("third-token!", start.Add(time.Minute))
+	expect("third-token!")
+
+	write("", start.Add(2*time.Minute))
+	if _, err := src.Token(); err == nil {
+		t.Error("empty token file was accepted")
+	}
+}
+
+func TestAuthRetriesUnauthorized(t *testing.T) {
+	var seen []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Authorization")+" "+string(body))
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	var calls int32
+	config := testConfig(srv.URL)
+	config.AuthToken = ""
+	config.TokenSource = NewCachedTokenSource(countingSource(&calls, time.Hour))
+
+	cl, _ := NewClient("vbase", config, false)
+	if _, err := cl.Post().AddPath("/file").BodyString("content").Send(); err != nil {
+		t.Fatalf("request failed after a new token: %v", err)
+	}
+	if len(seen) != 2 || seen[0] != "Bearer token-1 content" || seen[1] != "Bearer token-2 content" {
+		t.Errorf("server saw %q, want the POST sent again in whole with the new token", seen)
+	}
+
+	// A source that gives the same token again is not retried
+	seen = nil
+	config.TokenSource = StaticTokenSource("token-1")
+	cl, _ = NewClient("vbase", config, false)
+	if _, err := cl.Get().AddPath("/file").Send(); err == nil {
+		t.Error("request rejected with 401 succeeded")
+	}
+	if len(seen) != 1 {
+		t.Errorf("got %d requests, want 1 as there is no other token", len(seen))
+	}
+}
@@ -0,0 +1,194 @@
+package clients
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/h2non/gentleman.v1/context"
+	"gopkg.in/h2non/gentleman.v1/plugin"
+)
+
+// W3C Trace Context headers, see https://www.w3.org/TR/trace-context/
+const (
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+)
+
+// traceParentPattern matches the fields of version 00 of traceparent, which
+// later versions may follow with fields of their own
+var traceParentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// traceParent is the W3C trace context the outbound calls of a request
+// descend from
+type traceParent struct {
+	traceID string
+	// spanID is the span of the incoming request, empty for a new trace
+	spanID string
+	flags  string
+	state  string
+}
+
+// parseTraceParent reads the trace context of an incoming request, starting
+// a new trace when it has none or an invalid one. Versions above 00 are read
+// as version 00, ignoring their extra fields and unknown flags, as the spec
+// requires.
+func parseTraceParent(h http.Header) *traceParent {
+	m := traceParentPattern.FindStringSubmatch(strings.TrimSpace(h.Get(traceParentHeader)))
+	if m == nil || m[1] == "ff" || (m[1] == "00" && m[5] != "") ||
+		m[2] == strings.Repeat("0", 32) || m[3] == strings.Repeat("0", 16) {
+		return &traceParent{traceID: randomID(16), flags: "01"}
+	}
+
+	flags := m[4]
+	if m[1] != "00" {
+		// Only the sampled flag is known
+		if bits, _ := strconv.ParseUint(flags, 16, 8); bits&1 == 1 {
+			flags = "01"
+		} else {
+			flags = "00"
+		}
+	}
+
+	return &traceParent{
+		traceID: m[2],
+		spanID:  m[3],
+		flags:   flags,
+		state:   h.Get(traceStateHeader),
+	}
+}
+
+func randomID(bytes int) string {
+	buf := make([]byte, bytes)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Span describes an attempt of an outbound call as part of a distributed
+// trace. Retries and failovers to other regions get spans of their own.
+type Span struct {
+	TraceID string
+	SpanID  string
+	// ParentID is the span of the incoming request, empty for a new trace
+	ParentID   string
+	Service    string
+	Method     string
+	URL        string
+	StatusCode int
+	Start      time.Time
+	Duration   time.Duration
+	// Err is the transport error of the attempt, if any. Error responses are
+	// told by StatusCode.
+	Err error
+}
+
+// SpanExporter receives a span for every outbound call once it finishes
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// InMemoryExporter keeps exported spans in memory, e.g. for tests
+type InMemoryExporter struct {
+	sync.Mutex
+	spans []*Span
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the spans exported so far, in the order they finished
+func (e *InMemoryExporter) Spans() []*Span {
+	e.Lock()
+	defer e.Unlock()
+
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset discards the spans exported so far
+func (e *InMemoryExporter) Reset() {
+	e.Lock()
+	defer e.Unlock()
+
+	e.spans = nil
+}
+
+const tracerKey = "tracer"
+
+// tracer holds what the attempts of a call need to join the trace of the
+// incoming request
+type tracer struct {
+	service  string
+	parent   *traceParent
+	exporter SpanExporter
+}
+
+// propagateTrace puts the calls made on behalf of a request in its trace.
+// Each of their attempts is traced by the transport of traceAttempts.
+func propagateTrace(service string, reqCtx RequestContext, exporter SpanExporter) plugin.Plugin {
+	return plugin.NewRequestPlugin(func(c *context.Context, h context.Handler) {
+		c.Set(tracerKey, &tracer{service, reqCtx.traceParent(), exporter})
+		h.Next(c)
+	})
+}
+
+// traceAttempts wraps the transport of each traced request in a
+// traceTransport. It must be registered before the plugins that send a
+// request more than once, so that it wraps the transport of each attempt.
+func traceAttempts() plugin.Plugin {
+	return plugin.NewPhasePlugin("before dial", func(c *context.Context, h context.Handler) {
+		if t, ok := c.GetOk(tracerKey); ok {
+			c.Client.Transport = &traceTransport{next: c.Client.Transport, tracer: t.(*tracer)}
+		}
+		h.Next(c)
+	})
+}
+
+// traceTransport gives every attempt of a call its own span, and exports it
+// once finished if the tracer has an exporter.
+type traceTransport struct {
+	next   http.RoundTripper
+	tracer *tracer
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := t.tracer.parent
+	span := &Span{
+		TraceID:  parent.traceID,
+		SpanID:   randomID(8),
+		ParentID: parent.spanID,
+		Service:  t.tracer.service,
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Start:    time.Now(),
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(traceParentHeader, "00-"+span.TraceID+"-"+span.SpanID+"-"+parent.flags)
+	if parent.state != "" {
+		req.Header.Set(traceStateHeader, parent.state)
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if t.tracer.exporter != nil {
+		span.Duration = time.Since(span.Start)
+		if res != nil {
+			span.StatusCode = res.StatusCode
+		}
+		span.Err = err
+		t.tracer.exporter.ExportSpan(span)
+	}
+	return res, err
+}
@@ -0,0 +1,94 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	for _, c := range []struct {
+		header string
+		// flags is empty when a new trace must be started
+		flags string
+	}{
+		{"00-" + testTraceID + "-" + testSpanID + "-01", "01"},
+		{"00-" + testTraceID + "-" + testSpanID + "-00", "00"},
+		{"01-" + testTraceID + "-" + testSpanID + "-01", "01"},
+		{"cc-" + testTraceID + "-" + testSpanID + "-09-what-the-future-holds", "01"},
+		{"cc-" + testTraceID + "-" + testSpanID + "-08-what-the-future-holds", "00"},
+		{"01-" + testTraceID + "-" + testSpanID + "-0a", "00"},
+		{"01-" + testTraceID + "-" + testSpanID + "-0b", "01"},
+		{"01-" + testTraceID + "-" + testSpanID + "-ff", "01"},
+		{"01-" + testTraceID + "-" + testSpanID + "-01", "01"},
+		{"00-" + testTraceID + "-" + testSpanID + "-01-extra", ""},
+		{"cc-" + testTraceID + "-" + testSpanID + "-01extra", ""},
+		{"ff-" + testTraceID + "-" + testSpanID + "-01", ""},
+		{"00-" + strings.ToUpper(testTraceID) + "-" + testSpanID + "-01", ""},
+		{"00-" + strings.Repeat("0", 32) + "-" + testSpanID + "-01", ""},
+		{"00-" + testTraceID + "-" + strings.Repeat("0", 16) + "-01", ""},
+		{"", ""},
+	} {
+		parent := parseTraceParent(http.Header{"Traceparent": {c.header}})
+		if c.flags == "" {
+			if parent.traceID == testTraceID || parent.spanID != "" {
+				t.Errorf("%q was accepted, want a new trace", c.header)
+			}
+			continue
+		}
+		if parent.traceID != testTraceID || parent.spanID != testSpanID || parent.flags != c.flags {
+			t.Errorf("%q was read as %+v, want flags %s", c.header, parent, c.flags)
+		}
+	}
+}
+
+func TestTraceSpanPerAttempt(t *testing.T) {
+	rec := &recorder{statuses: []int{503, 200}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	incoming, _ := http.NewRequest(http.MethodGet, "/", nil)
+	incoming.Header.Set("traceparent", "00-"+testTraceID+"-"+testSpanID+"-01")
+	incoming.Header.Set("tracestate", "vendor=value")
+
+	exporter := NewInMemoryExporter()
+	config := testConfig(srv.URL)
+	config.RequestContext = NewRequestContext(incoming)
+	config.RetryPolicy = fastRetries()
+	config.SpanExporter = exporter
+
+	cl, _ := NewClient("vbase", config, false)
+	if _, err := cl.Get().AddPath("/file").Send(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 2 || rec.count() != 2 {
+		t.Fatalf("got %d spans for %d attempts, want 2 for 2", len(spans), rec.count())
+	}
+	if spans[0].SpanID == spans[1].SpanID {
+		t.Error("the retry reused the span of the first attempt")
+	}
+	for i, span := range spans {
+		if span.TraceID != testTraceID || span.ParentID != testSpanID {
+			t.Errorf("span %d is %+v, want a child of the incoming span", i, span)
+		}
+		if want := []int{503, 200}[i]; span.StatusCode != want {
+			t.Errorf("span %d has status %d, want %d", i, span.StatusCode, want)
+		}
+
+		header := rec.requests[i].header
+		if want := "00-" + testTraceID + "-" + span.SpanID + "-01"; header.Get("traceparent") != want {
+			t.Errorf("attempt %d sent traceparent %q, want %q", i, header.Get("traceparent"), want)
+		}
+		if header.Get("tracestate") != "vendor=value" {
+			t.Errorf("attempt %d sent tracestate %q, want the incoming one", i, header.Get("tracestate"))
+		}
+	}
+}
@@ -2,6 +2,7 @@ package colossus
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 
 	"github.com/vtex/go-clients/clients"
@@ -13,6 +14,7 @@ type Colossus interface {
 	SendEventB(sender, subject, key string, body []byte) error
 	SendLogJ(sender, subject, level string, body interface{}) error
 	SendLogB(sender, subject, level string, body []byte) error
+	WithContext(ctx context.Context) Colossus
 }
 
 type Client struct {
@@ -24,6 +26,20 @@ func NewClient(config *clients.Config) Colossus {
 	return &Client{cl}
 }
 
+// New creates a Colossus client, or returns an error if config is invalid
+func New(config *clients.Config) (Colossus, error) {
+	cl, err := clients.NewClient("colossus", config, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{cl}, nil
+}
+
+// WithContext returns a view of the client whose requests are bound to ctx
+func (cl *Client) WithContext(ctx context.Context) Colossus {
+	return &Client{clients.WithContext(cl.http, ctx)}
+}
+
 const (
 	eventPath = "/events/%v/%v/%v"
 	logPath   = "/logs/%v/%v/%v"
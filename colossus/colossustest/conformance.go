@@ -0,0 +1,89 @@
+// Package colossustest checks that implementations of colossus.Colossus
+// behave as the client does against the service, so that fakes and
+// alternative backends can't drift from it.
+package colossustest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vtex/go-clients/colossus"
+)
+
+// Message is an event or log entry received by the backend under test. The
+// key of log entries is their level.
+type Message struct {
+	Sender  string
+	Subject string
+	Key     string
+	Body    []byte
+}
+
+// Factory returns a Colossus, along with functions returning the events and
+// the log entries its backend received so far, in order.
+type Factory func(t *testing.T) (c colossus.Colossus, events, logs func() []Message)
+
+// RunConformance runs the conformance suite as subtests of t, each on a new
+// Colossus from factory.
+func RunConformance(t *testing.T, factory Factory) {
+	t.Run("Events", func(t *testing.T) {
+		c, events, _ := factory(t)
+		if err := c.SendEventJ("sender", "subject", "json", map[string]int{"count": 1}); err != nil {
+			t.Fatalf("SendEventJ: %v", err)
+		}
+		if err := c.SendEventB("sender", "subject", "bytes", []byte("content")); err != nil {
+			t.Fatalf("SendEventB: %v", err)
+		}
+		checkMessages(t, "events", events(), []Message{
+			{"sender", "subject", "json", []byte(`{"count":1}`)},
+			{"sender", "subject", "bytes", []byte("content")},
+		})
+	})
+
+	t.Run("Logs", func(t *testing.T) {
+		c, _, logs := factory(t)
+		if err := c.SendLogJ("sender", "subject", "info", map[string]int{"count": 1}); err != nil {
+			t.Fatalf("SendLogJ: %v", err)
+		}
+		if err := c.SendLogB("sender", "subject", "error", []byte("content")); err != nil {
+			t.Fatalf("SendLogB: %v", err)
+		}
+		checkMessages(t, "logs", logs(), []Message{
+			{"sender", "subject", "info", []byte(`{"count":1}`)},
+			{"sender", "subject", "error", []byte("content")},
+		})
+	})
+
+	t.Run("WithContext", func(t *testing.T) {
+		c, events, _ := factory(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := c.WithContext(ctx).SendEventB("sender", "subject", "key", nil); !errors.Is(err, context.Canceled) {
+			t.Errorf("SendEventB with a canceled context: got %v, want context.Canceled", err)
+		}
+		checkMessages(t, "events", events(), nil)
+	})
+}
+
+// checkMessages compares messages, ignoring a trailing newline after JSON
+// bodies
+func checkMessages(t *testing.T, kind string, got, want []Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d %s, want %d", len(got), kind, len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Sender != w.Sender || g.Subject != w.Subject || g.Key != w.Key || trimNewline(g.Body) != string(w.Body) {
+			t.Errorf("%s[%d]: got %s/%s/%s %q, want %s/%s/%s %q", kind, i, g.Sender, g.Subject, g.Key, g.Body, w.Sender, w.Subject, w.Key, w.Body)
+		}
+	}
+}
+
+func trimNewline(body []byte) string {
+	if n := len(body); n > 0 && body[n-1] == '\n' {
+		return string(body[:n-1])
+	}
+	return string(body)
+}
@@ -0,0 +1,53 @@
+package colossustest
+
+import (
+	"testing"
+
+	"github.com/vtex/go-clients/colossus"
+	"github.com/vtex/go-clients/fakes"
+	"github.com/vtex/go-clients/standin"
+)
+
+func TestFakeConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) (colossus.Colossus, func() []Message, func() []Message) {
+		c := fakes.NewColossus()
+		events := func() (messages []Message) {
+			for _, e := range c.Events() {
+				messages = append(messages, Message{e.Sender, e.Subject, e.Key, e.Body})
+			}
+			return messages
+		}
+		logs := func() (messages []Message) {
+			for _, l := range c.Logs() {
+				messages = append(messages, Message{l.Sender, l.Subject, l.Level, l.Body})
+			}
+			return messages
+		}
+		return c, events, logs
+	})
+}
+
+func TestClientConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) (colossus.Colossus, func() []Message, func() []Message) {
+		s := standin.New()
+		t.Cleanup(s.Close)
+
+		c, err := colossus.New(s.Config("account", "workspace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := func() (messages []Message) {
+			for _, e := range s.Events() {
+				messages = append(messages, Message{e.Sender, e.Subject, e.Key, e.Body})
+			}
+			return messages
+		}
+		logs := func() (messages []Message) {
+			for _, l := range s.Logs() {
+				messages = append(messages, Message{l.Sender, l.Subject, l.Level, l.Body})
+			}
+			return messages
+		}
+		return c, events, logs
+	})
+}
@@ -0,0 +1,284 @@
+package fakes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vtex/go-clients/apps"
+	"github.com/vtex/go-clients/internal/inmem"
+	"gopkg.in/h2non/gentleman.v1"
+)
+
+var (
+	_ apps.Apps     = &Apps{}
+	_ apps.Registry = &Registry{}
+)
+
+// Apps is an in-memory apps.Apps of a single workspace. The parent of the
+// calls is ignored.
+type Apps struct {
+	store *appsStore
+	ctx   context.Context
+}
+
+type appsStore struct {
+	mu           sync.Mutex
+	apps         map[string]*installedApp
+	dependencies map[string][]string
+}
+
+type installedApp struct {
+	manifest *apps.ActiveApp
+	files    map[string][]byte
+}
+
+// NewApps creates an Apps without installed apps
+func NewApps() *Apps {
+	return &Apps{store: &appsStore{apps: map[string]*installedApp{}, dependencies: map[string][]string{}}}
+}
+
+// WithContext returns a view of the fake, sharing its apps, whose calls fail
+// once ctx is done.
+func (a *Apps) WithContext(ctx context.Context) apps.Apps {
+	return &Apps{a.store, ctx}
+}
+
+// Install installs an app, replacing any other version of it. Its files are
+// served by path, and bundles are the gzipped tarballs of the files under a
+// folder.
+func (a *Apps) Install(app *apps.ActiveApp, files map[string][]byte) {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	name := app.Vendor + "." + app.Name
+	if app.ID == "" {
+		app.ID = name + "@" + app.Version
+	}
+	a.store.apps[name] = &installedApp{app, files}
+}
+
+// SetDependencies sets the dependencies returned by GetDependencies
+func (a *Apps) SetDependencies(dependencies map[string][]string) {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+	a.store.dependencies = dependencies
+}
+
+// app returns an installed app, given its name with or without a version
+func (a *Apps) app(app string) (*installedApp, error) {
+	if err := contextErr(a.ctx); err != nil {
+		return nil, err
+	}
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	installed, ok := a.store.apps[strings.SplitN(app, "@", 2)[0]]
+	if !ok {
+		return nil, notFound("apps", "AppNotFound", "App "+app+" is not installed")
+	}
+	return installed, nil
+}
+
+// GetApp describes an installed app's manifest
+func (a *Apps) GetApp(app, parentID string) (*apps.ActiveApp, string, error) {
+	installed, err := a.app(app)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var manifest apps.ActiveApp
+	eTag, err := copyManifest(installed.manifest, &manifest)
+	if err != nil {
+		return nil, "", err
+	}
+	return &manifest, eTag, nil
+}
+
+func (a *Apps) ListFiles(app, parentID string) (*apps.FileList, string, error) {
+	installed, err := a.app(app)
+	if err != nil {
+		return nil, "", err
+	}
+	return fileList(installed.files), "", nil
+}
+
+// GetFile gets an installed app's file as a response
+func (a *Apps) GetFile(app, parentID, path string) (*gentleman.Response, string, error) {
+	installed, err := a.app(app)
+	if err != nil {
+		return nil, "", err
+	}
+	return appFile("apps", installed.files, path)
+}
+
+func (a *Apps) GetBundle(app, parentID, rootFolder string) (io.Reader, string, error) {
+	installed, err := a.app(app)
+	if err != nil {
+		return nil, "", err
+	}
+	return appBundle(installed.files, rootFolder)
+}
+
+func (a *Apps) GetDependencies() (map[string][]string, string, error) {
+	if err := contextErr(a.ctx); err != nil {
+		return nil, "", err
+	}
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	dependencies := make(map[string][]string, len(a.store.dependencies))
+	for app, deps := range a.store.dependencies {
+		dependencies[app] = append([]string(nil), deps...)
+	}
+	return dependencies, "", nil
+}
+
+// Registry is an in-memory apps.Registry
+type Registry struct {
+	store *registryStore
+	ctx   context.Context
+}
+
+type registryStore struct {
+	mu   sync.Mutex
+	apps map[string]*publishedApp
+}
+
+type publishedApp struct {
+	manifest *apps.PublishedApp
+	files    map[string][]byte
+}
+
+// NewRegistry creates a Registry without published apps
+func NewRegistry() *Registry {
+	return &Registry{store: &registryStore{apps: map[string]*publishedApp{}}}
+}
+
+// WithContext returns a view of the fake, sharing its apps, whose calls fail
+// once ctx is done.
+func (r *Registry) WithContext(ctx context.Context) apps.Registry {
+	return &Registry{r.store, ctx}
+}
+
+// Publish publishes an app version
+func (r *Registry) Publish(app *apps.PublishedApp, files map[string][]byte) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	id := app.Vendor + "." + app.Name + "@" + app.Version
+	if app.ID == "" {
+		app.ID = id
+	}
+	r.store.apps[id] = &publishedApp{app, files}
+}
+
+func (r *Registry) app(id string) (*publishedApp, error) {
+	if !strings.Contains(id, "@") {
+		return nil, fmt.Errorf("Not a composed app identifier: %s", id)
+	}
+	if err := contextErr(r.ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	published, ok := r.store.apps[id]
+	if !ok {
+		return nil, notFound("apps", "AppNotFound", "App "+id+" is not published")
+	}
+	return published, nil
+}
+
+// GetApp returns the app metadata
+func (r *Registry) GetApp(id string) (*apps.PublishedApp, string, error) {
+	published, err := r.app(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var manifest apps.PublishedApp
+	eTag, err := copyManifest(published.manifest, &manifest)
+	if err != nil {
+		return nil, "", err
+	}
+	return &manifest, eTag, nil
+}
+
+func (r *Registry) ListFiles(id string) (*apps.FileList, string, error) {
+	published, err := r.app(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return fileList(published.files), "", nil
+}
+
+func (r *Registry) GetFile(id, path string) (*gentleman.Response, string, error) {
+	published, err := r.app(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return appFile("apps", published.files, path)
+}
+
+func (r *Registry) GetBundle(id, rootFolder string) (io.Reader, string, error) {
+	published, err := r.app(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return appBundle(published.files, rootFolder)
+}
+
+// copyManifest copies a manifest through JSON, as it would be received, and
+// returns its ETag.
+func copyManifest(manifest, dst interface{}) (string, error) {
+	buf, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(buf, dst); err != nil {
+		return "", err
+	}
+	return hash(buf), nil
+}
+
+func fileList(files map[string][]byte) *apps.FileList {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	list := &apps.FileList{Files: []*apps.File{}}
+	for _, path := range paths {
+		list.Files = append(list.Files, &apps.File{Path: path, Hash: hash(files[path])})
+	}
+	return list
+}
+
+func appFile(service string, files map[string][]byte, path string) (*gentleman.Response, string, error) {
+	content, ok := files[path]
+	if !ok {
+		return nil, "", notFound(service, "FileNotFound", "File "+path+" not found")
+	}
+
+	eTag := hash(content)
+	res, err := response(eTag, "", content)
+	if err != nil {
+		return nil, "", err
+	}
+	return res, eTag, nil
+}
+
+func appBundle(files map[string][]byte, rootFolder string) (io.Reader, string, error) {
+	content, err := inmem.Bundle(files, rootFolder)
+	if err != nil {
+		return nil, "", err
+	}
+	return bytes.NewReader(content), hash(content), nil
+}
@@ -0,0 +1,101 @@
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/vtex/go-clients/colossus"
+)
+
+var _ colossus.Colossus = &Colossus{}
+
+// Colossus is an in-memory colossus.Colossus that captures what is sent, for
+// tests to assert on.
+type Colossus struct {
+	store *colossusStore
+	ctx   context.Context
+}
+
+type colossusStore struct {
+	mu     sync.Mutex
+	events []*Event
+	logs   []*Log
+}
+
+// Event is an event sent to a Colossus
+type Event struct {
+	Sender  string
+	Subject string
+	Key     string
+	Body    []byte
+}
+
+// Log is a log entry sent to a Colossus
+type Log struct {
+	Sender  string
+	Subject string
+	Level   string
+	Body    []byte
+}
+
+// NewColossus creates a Colossus with nothing sent yet
+func NewColossus() *Colossus {
+	return &Colossus{store: &colossusStore{}}
+}
+
+// WithContext returns a view of the fake, sharing what is sent, whose calls
+// fail once ctx is done.
+func (c *Colossus) WithContext(ctx context.Context) colossus.Colossus {
+	return &Colossus{c.store, ctx}
+}
+
+// Events returns the events sent so far, in order
+func (c *Colossus) Events() []*Event {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	return append([]*Event(nil), c.store.events...)
+}
+
+// Logs returns the log entries sent so far, in order
+func (c *Colossus) Logs() []*Log {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	return append([]*Log(nil), c.store.logs...)
+}
+
+func (c *Colossus) SendEventJ(sender, subject, key string, body interface{}) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.SendEventB(sender, subject, key, buf)
+}
+
+func (c *Colossus) SendEventB(sender, subject, key string, body []byte) error {
+	if err := contextErr(c.ctx); err != nil {
+		return err
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	c.store.events = append(c.store.events, &Event{sender, subject, key, append([]byte(nil), body...)})
+	return nil
+}
+
+func (c *Colossus) SendLogJ(sender, subject, level string, body interface{}) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.SendLogB(sender, subject, level, buf)
+}
+
+func (c *Colossus) SendLogB(sender, subject, level string, body []byte) error {
+	if err := contextErr(c.ctx); err != nil {
+		return err
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	c.store.logs = append(c.store.logs, &Log{sender, subject, level, append([]byte(nil), body...)})
+	return nil
+}
@@ -0,0 +1,87 @@
+// Package fakes provides thread-safe in-memory implementations of the client
+// interfaces, for tests of code that uses the clients. They behave as the
+// clients talking to the services do: listings are paged with markers, ETags
+// change whenever content does, missing keys fail with a ResponseError that
+// matches clients.ErrNotFound, and failed preconditions with one that matches
+// clients.ErrPreconditionFailed.
+//
+// Every fake shares its state with the views returned by WithContext, whose
+// calls fail with the context error once it is done.
+package fakes
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/internal/inmem"
+	"gopkg.in/h2non/gentleman.v1"
+	"gopkg.in/h2non/gentleman.v1/plugins/transport"
+)
+
+func notFound(service, code, message string) error {
+	return clients.ResponseError{Service: service, StatusCode: http.StatusNotFound, Code: code, Message: message}
+}
+
+func preconditionFailed(service, message string) error {
+	return clients.ResponseError{Service: service, StatusCode: http.StatusPreconditionFailed, Code: "PreconditionFailed", Message: message}
+}
+
+// checkPrecondition mirrors the conditional writes of the clients: an empty
+// ifMatch requires the resource not to exist, otherwise its eTag must match.
+func checkPrecondition(service, ifMatch, eTag string) error {
+	if ifMatch == "" && eTag != "" {
+		return preconditionFailed(service, "The resource already exists")
+	}
+	if ifMatch != "" && ifMatch != eTag {
+		return preconditionFailed(service, "The resource changed")
+	}
+	return nil
+}
+
+func hash(content []byte) string {
+	return inmem.Hash(content)
+}
+
+// contextErr returns the error of ctx once it is done
+func contextErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// response returns a successful gentleman response with the given content,
+// as returned by the clients that stream files.
+func response(eTag, contentType string, content []byte) (*gentleman.Response, error) {
+	header := http.Header{}
+	if eTag != "" {
+		header.Set(clients.HeaderETag, eTag)
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	cl := gentleman.New().Use(transport.Set(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			Status:        http.StatusText(http.StatusOK),
+			StatusCode:    http.StatusOK,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(content)),
+			ContentLength: int64(len(content)),
+			Request:       req,
+		}, nil
+	})))
+	return cl.Get().URL("http://fakes.invalid/").Send()
+}
@@ -0,0 +1,421 @@
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/internal/inmem"
+	"github.com/vtex/go-clients/metadata"
+)
+
+var _ metadata.Metadata = &Metadata{}
+
+// Metadata is an in-memory metadata.Metadata. Buckets exist as soon as they
+// are used.
+type Metadata struct {
+	store    *metadataStore
+	resolver metadata.ConflictResolver
+	ctx      context.Context
+}
+
+type metadataStore struct {
+	mu      sync.Mutex
+	buckets map[string]*metadataBucket
+}
+
+type metadataBucket struct {
+	state     string
+	values    map[string]json.RawMessage
+	conflicts []*metadata.MetadataConflict
+}
+
+// NewMetadata creates an empty Metadata. As with metadata.NewClient, the
+// resolver is optional, and conflicts are only detected when it is set.
+func NewMetadata(resolver metadata.ConflictResolver) *Metadata {
+	return &Metadata{store: &metadataStore{buckets: map[string]*metadataBucket{}}, resolver: resolver}
+}
+
+// WithContext returns a view of the fake, sharing its keys, whose calls fail
+// once ctx is done.
+func (m *Metadata) WithContext(ctx context.Context) metadata.Metadata {
+	return &Metadata{m.store, m.resolver, ctx}
+}
+
+// AddConflict adds a conflict to a bucket, listed by ListAllConflicts until
+// ResolveConflicts patches its key.
+func (m *Metadata) AddConflict(bucket string, c *metadata.MetadataConflict) {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	b := m.store.bucket(bucket)
+	b.conflicts = append(b.conflicts, c)
+}
+
+func (s *metadataStore) bucket(name string) *metadataBucket {
+	b, ok := s.buckets[name]
+	if !ok {
+		b = &metadataBucket{values: map[string]json.RawMessage{}}
+		s.buckets[name] = b
+	}
+	return b
+}
+
+// copyFrom replaces the buckets of s with copies of the ones of src. Values
+// are replaced rather than changed on writes, so they are shared.
+func (s *metadataStore) copyFrom(src *metadataStore) {
+	src.mu.Lock()
+	buckets := make(map[string]*metadataBucket, len(src.buckets))
+	for name, b := range src.buckets {
+		c := &metadataBucket{
+			state:     b.state,
+			values:    make(map[string]json.RawMessage, len(b.values)),
+			conflicts: append([]*metadata.MetadataConflict(nil), b.conflicts...),
+		}
+		for key, value := range b.values {
+			c.values[key] = value
+		}
+		buckets[name] = c
+	}
+	src.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets = buckets
+}
+
+// hash changes whenever a key of the bucket does
+func (b *metadataBucket) hash() string {
+	entries := make([]string, 0, len(b.values))
+	for key, value := range b.values {
+		entries = append(entries, key+":"+hash(value))
+	}
+	sort.Strings(entries)
+	return hash([]byte(b.state + "\n" + strings.Join(entries, "\n")))
+}
+
+func (b *metadataBucket) eTag(key string) string {
+	if value, ok := b.values[key]; ok {
+		return hash(value)
+	}
+	return ""
+}
+
+func keyNotFound(key string) error {
+	return notFound("kube-router", "KeyNotFound", "Key "+key+" not found")
+}
+
+// perform runs op on a bucket under the lock. When a resolver is set and the
+// bucket has conflicts, the resolver is called outside of the lock and op is
+// retried once it resolved them, as the client does.
+func (m *Metadata) perform(bucket string, op func(b *metadataBucket) error) error {
+	for {
+		if err := contextErr(m.ctx); err != nil {
+			return err
+		}
+
+		m.store.mu.Lock()
+		b := m.store.bucket(bucket)
+		conflicted := m.resolver != nil && len(b.conflicts) > 0
+		var err error
+		if !conflicted {
+			err = op(b)
+		}
+		m.store.mu.Unlock()
+		if !conflicted {
+			return err
+		}
+
+		resolved, err := m.resolver.Resolve(m, bucket)
+		if err != nil {
+			return fmt.Errorf("Error resolving conflicts: %v", err)
+		} else if !resolved {
+			return clients.ResponseError{
+				Service:    "kube-router",
+				StatusCode: http.StatusConflict,
+				Code:       "Conflict",
+				Message:    "The bucket has unresolved conflicts",
+			}
+		}
+	}
+}
+
+func (m *Metadata) GetBucket(bucket string) (*metadata.BucketResponse, string, error) {
+	if err := contextErr(m.ctx); err != nil {
+		return nil, "", err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	eTag := m.store.bucket(bucket).hash()
+	return &metadata.BucketResponse{Hash: eTag}, eTag, nil
+}
+
+func (m *Metadata) SetBucketState(bucket, state string) error {
+	if err := contextErr(m.ctx); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.bucket(bucket).state = state
+	return nil
+}
+
+func (m *Metadata) List(bucket string, options *metadata.Options) (*metadata.MetadataListResponse, string, error) {
+	if options.Limit <= 0 {
+		options.Limit = 10
+	}
+
+	var list *metadata.MetadataListResponse
+	var eTag string
+	err := m.perform(bucket, func(b *metadataBucket) error {
+		keys := make([]string, 0, len(b.values))
+		for key := range b.values {
+			keys = append(keys, key)
+		}
+
+		page, next := inmem.Page(keys, options.Marker, options.Limit)
+		list = &metadata.MetadataListResponse{Data: []*metadata.MetadataResponseEntry{}, NextMarker: next}
+		for _, key := range page {
+			entry := &metadata.MetadataResponseEntry{Key: key, Hash: hash(b.values[key])}
+			if options.IncludeValue {
+				entry.Value = b.values[key]
+			}
+			list.Data = append(list.Data, entry)
+		}
+		eTag = b.hash()
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return list, eTag, nil
+}
+
+func (m *Metadata) ListAll(bucket string, includeValue bool) (*metadata.MetadataListResponse, string, error) {
+	options := &metadata.Options{Limit: 100, IncludeValue: includeValue}
+
+	list, eTag, err := m.List(bucket, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for list.NextMarker != "" {
+		options.Marker = list.NextMarker
+		partialList, newETag, err := m.List(bucket, options)
+		if err != nil {
+			return nil, "", err
+		}
+
+		list.Data = append(list.Data, partialList.Data...)
+		list.NextMarker = partialList.NextMarker
+		eTag = newETag
+	}
+	return list, eTag, nil
+}
+
+func (m *Metadata) Get(bucket, key string, data interface{}) (string, error) {
+	eTag, _, err := m.get(bucket, key, data, nil)
+	return eTag, err
+}
+
+// GetIfNoneMatch reads a key only if its ETag is no longer eTag
+func (m *Metadata) GetIfNoneMatch(bucket, key string, data interface{}, eTag string) (string, bool, error) {
+	return m.get(bucket, key, data, &eTag)
+}
+
+func (m *Metadata) get(bucket, key string, data interface{}, ifNoneMatch *string) (string, bool, error) {
+	var value json.RawMessage
+	err := m.perform(bucket, func(b *metadataBucket) error {
+		var ok bool
+		if value, ok = b.values[key]; !ok {
+			return keyNotFound(key)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	eTag := hash(value)
+	if ifNoneMatch != nil && *ifNoneMatch == eTag {
+		return eTag, false, nil
+	}
+	if err := json.Unmarshal(value, data); err != nil {
+		return "", false, err
+	}
+	return eTag, true, nil
+}
+
+func (m *Metadata) Save(bucket, key string, data interface{}) (string, error) {
+	return m.save(bucket, key, data, nil)
+}
+
+// SaveIfMatch saves a key only if its current ETag is eTag, or only if it
+// doesn't exist when eTag is empty.
+func (m *Metadata) SaveIfMatch(bucket, key string, data interface{}, eTag string) (string, error) {
+	return m.save(bucket, key, data, &eTag)
+}
+
+func (m *Metadata) save(bucket, key string, data interface{}, ifMatch *string) (string, error) {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	err = m.perform(bucket, func(b *metadataBucket) error {
+		if ifMatch != nil {
+			if err := checkPrecondition("kube-router", *ifMatch, b.eTag(key)); err != nil {
+				return err
+			}
+		}
+		b.values[key] = value
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hash(value), nil
+}
+
+func (m *Metadata) SaveAll(bucket string, data map[string]interface{}) (string, error) {
+	values := make(map[string]json.RawMessage, len(data))
+	for key, v := range data {
+		value, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		values[key] = value
+	}
+
+	var eTag string
+	err := m.perform(bucket, func(b *metadataBucket) error {
+		for key, value := range values {
+			b.values[key] = value
+		}
+		eTag = b.hash()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return eTag, nil
+}
+
+func (m *Metadata) DoAll(bucket string, patch metadata.MetadataPatchRequest) error {
+	toSave := map[string]interface{}{}
+	var errMsgs []string
+	for _, op := range patch {
+		switch op.Type {
+		case metadata.OperationTypeAdd, metadata.OperationTypeReplace:
+			toSave[op.Key] = op.Value
+		case metadata.OperationTypeRemove:
+			if _, err := m.Delete(bucket, op.Key); err != nil {
+				errMsgs = append(errMsgs, fmt.Sprintf("Delete %s: %v", op.Key, err))
+			}
+		}
+	}
+
+	if len(toSave) > 0 {
+		if _, err := m.SaveAll(bucket, toSave); err != nil {
+			keys := make([]string, 0, len(toSave))
+			for key := range toSave {
+				keys = append(keys, key)
+			}
+			errMsgs = append(errMsgs, fmt.Sprintf("Save keys %v: %v", keys, err))
+		}
+	}
+
+	if len(errMsgs) > 0 {
+		return fmt.Errorf("Error(s) in metadata patch in bucket %s: %s", bucket, strings.Join(errMsgs, "; "))
+	}
+	return nil
+}
+
+// Delete deletes a key, returning false if it didn't exist
+func (m *Metadata) Delete(bucket, key string) (bool, error) {
+	return m.delete(bucket, key, nil)
+}
+
+// DeleteIfMatch deletes a key only if its current ETag is eTag, failing as
+// well if it is already gone
+func (m *Metadata) DeleteIfMatch(bucket, key, eTag string) (bool, error) {
+	if eTag == "" {
+		return false, clients.ErrMissingETag
+	}
+	return m.delete(bucket, key, &eTag)
+}
+
+func (m *Metadata) delete(bucket, key string, ifMatch *string) (bool, error) {
+	var deleted bool
+	err := m.perform(bucket, func(b *metadataBucket) error {
+		if ifMatch != nil {
+			if err := checkPrecondition("kube-router", *ifMatch, b.eTag(key)); err != nil {
+				return err
+			}
+		}
+		if _, deleted = b.values[key]; deleted {
+			delete(b.values, key)
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+func (m *Metadata) ListAllConflicts(bucket string) ([]*metadata.MetadataConflict, error) {
+	if err := contextErr(m.ctx); err != nil {
+		return nil, err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return append([]*metadata.MetadataConflict(nil), m.store.bucket(bucket).conflicts...), nil
+}
+
+// ResolveConflicts applies patch and clears the conflicts of the keys it
+// touches.
+func (m *Metadata) ResolveConflicts(bucket string, patch metadata.MetadataPatchRequest) error {
+	if err := contextErr(m.ctx); err != nil {
+		return err
+	}
+
+	values := map[string]json.RawMessage{}
+	for _, op := range patch {
+		if op.Type == metadata.OperationTypeAdd || op.Type == metadata.OperationTypeReplace {
+			value, err := json.Marshal(op.Value)
+			if err != nil {
+				return err
+			}
+			values[op.Key] = value
+		}
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bucket(bucket)
+	resolved := map[string]bool{}
+	for _, op := range patch {
+		switch op.Type {
+		case metadata.OperationTypeAdd, metadata.OperationTypeReplace:
+			b.values[op.Key] = values[op.Key]
+		case metadata.OperationTypeRemove:
+			delete(b.values, op.Key)
+		}
+		resolved[op.Key] = true
+	}
+
+	var remaining []*metadata.MetadataConflict
+	for _, c := range b.conflicts {
+		if !resolved[c.Key] {
+			remaining = append(remaining, c)
+		}
+	}
+	b.conflicts = remaining
+	return nil
+}
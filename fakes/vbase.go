@@ -0,0 +1,340 @@
+package fakes
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/internal/inmem"
+	"github.com/vtex/go-clients/vbase"
+	"gopkg.in/h2non/gentleman.v1"
+)
+
+var _ vbase.VBase = &VBase{}
+
+// VBase is an in-memory vbase.VBase. Buckets exist as soon as they are used.
+type VBase struct {
+	store *vbaseStore
+	ctx   context.Context
+}
+
+type vbaseStore struct {
+	mu      sync.Mutex
+	buckets map[string]*vbaseBucket
+}
+
+type vbaseBucket struct {
+	state     string
+	files     map[string]*vbaseFile
+	conflicts map[string]*vbase.Conflict
+}
+
+type vbaseFile struct {
+	content     []byte
+	contentType string
+	hash        string
+}
+
+// NewVBase creates an empty VBase
+func NewVBase() *VBase {
+	return &VBase{store: &vbaseStore{buckets: map[string]*vbaseBucket{}}}
+}
+
+// WithContext returns a view of the fake, sharing its files, whose calls fail
+// once ctx is done.
+func (v *VBase) WithContext(ctx context.Context) vbase.VBase {
+	return &VBase{v.store, ctx}
+}
+
+// AddConflict makes GetFileConflict return c for a file, until it is saved
+// or deleted.
+func (v *VBase) AddConflict(bucket, path string, c *vbase.Conflict) {
+	v.store.mu.Lock()
+	defer v.store.mu.Unlock()
+	v.store.bucket(bucket).conflicts[path] = c
+}
+
+func (s *vbaseStore) bucket(name string) *vbaseBucket {
+	b, ok := s.buckets[name]
+	if !ok {
+		b = &vbaseBucket{files: map[string]*vbaseFile{}, conflicts: map[string]*vbase.Conflict{}}
+		s.buckets[name] = b
+	}
+	return b
+}
+
+// copyFrom replaces the buckets of s with copies of the ones of src. Files are
+// replaced rather than changed on writes, so they are shared.
+func (s *vbaseStore) copyFrom(src *vbaseStore) {
+	src.mu.Lock()
+	buckets := make(map[string]*vbaseBucket, len(src.buckets))
+	for name, b := range src.buckets {
+		c := &vbaseBucket{
+			state:     b.state,
+			files:     make(map[string]*vbaseFile, len(b.files)),
+			conflicts: make(map[string]*vbase.Conflict, len(b.conflicts)),
+		}
+		for path, f := range b.files {
+			c.files[path] = f
+		}
+		for path, conflict := range b.conflicts {
+			c.conflicts[path] = conflict
+		}
+		buckets[name] = c
+	}
+	src.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets = buckets
+}
+
+// hash changes whenever a file of the bucket or its state does
+func (b *vbaseBucket) hash() string {
+	entries := make([]string, 0, len(b.files))
+	for path, f := range b.files {
+		entries = append(entries, path+":"+f.hash)
+	}
+	sort.Strings(entries)
+	return hash([]byte(b.state + "\n" + strings.Join(entries, "\n")))
+}
+
+func (b *vbaseBucket) file(path string) (*vbaseFile, string, error) {
+	f, ok := b.files[path]
+	if !ok {
+		return nil, "", notFound("vbase", "FileNotFound", "File "+path+" not found")
+	}
+	return f, f.hash, nil
+}
+
+func (b *vbaseBucket) eTag(path string) string {
+	if f, ok := b.files[path]; ok {
+		return f.hash
+	}
+	return ""
+}
+
+func (v *VBase) lock() (*vbaseStore, error) {
+	if err := contextErr(v.ctx); err != nil {
+		return nil, err
+	}
+	v.store.mu.Lock()
+	return v.store, nil
+}
+
+// GetBucket describes the current state of a bucket
+func (v *VBase) GetBucket(bucket string) (*vbase.BucketResponse, string, error) {
+	s, err := v.lock()
+	if err != nil {
+		return nil, "", err
+	}
+	defer s.mu.Unlock()
+
+	b := s.bucket(bucket)
+	return &vbase.BucketResponse{Hash: b.hash(), State: b.state}, b.hash(), nil
+}
+
+// SetBucketState sets the current state of a bucket
+func (v *VBase) SetBucketState(bucket, state string) (string, error) {
+	s, err := v.lock()
+	if err != nil {
+		return "", err
+	}
+	defer s.mu.Unlock()
+
+	s.bucket(bucket).state = state
+	return "", nil
+}
+
+// GetFile gets a file's content as a response
+func (v *VBase) GetFile(bucket, path string) (*gentleman.Response, string, error) {
+	s, err := v.lock()
+	if err != nil {
+		return nil, "", err
+	}
+	f, eTag, err := s.bucket(bucket).file(path)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := response(eTag, f.contentType, f.content)
+	if err != nil {
+		return nil, "", err
+	}
+	return res, eTag, nil
+}
+
+// GetFileIfNoneMatch gets a file's content only if its ETag is no longer eTag
+func (v *VBase) GetFileIfNoneMatch(bucket, path, eTag string) (*gentleman.Response, string, bool, error) {
+	s, err := v.lock()
+	if err != nil {
+		return nil, "", false, err
+	}
+	f, current, err := s.bucket(bucket).file(path)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	if current == eTag {
+		return nil, eTag, false, nil
+	}
+
+	res, err := response(current, f.contentType, f.content)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return res, current, true, nil
+}
+
+// GetFileConflict gets a file's content, or the conflict added to it
+func (v *VBase) GetFileConflict(bucket, path string) (*gentleman.Response, *vbase.Conflict, string, error) {
+	s, err := v.lock()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	b := s.bucket(bucket)
+	if c, ok := b.conflicts[path]; ok {
+		eTag := b.eTag(path)
+		s.mu.Unlock()
+		return nil, c, eTag, nil
+	}
+	s.mu.Unlock()
+
+	res, eTag, err := v.GetFile(bucket, path)
+	return res, nil, eTag, err
+}
+
+// SaveFile saves a file from a reader
+func (v *VBase) SaveFile(bucket, path string, body io.Reader) (string, error) {
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	_, err = v.save(bucket, path, content, "", nil)
+	return "", err
+}
+
+// SaveFileB saves a file
+func (v *VBase) SaveFileB(bucket, path string, content []byte, contentType string, unzip bool) (string, error) {
+	return v.save(bucket, path, content, contentType, nil)
+}
+
+// SaveFileIfMatch saves a file only if its current ETag is eTag, or only if
+// it doesn't exist when eTag is empty.
+func (v *VBase) SaveFileIfMatch(bucket, path string, content []byte, contentType string, unzip bool, eTag string) (string, error) {
+	return v.save(bucket, path, content, contentType, &eTag)
+}
+
+// save stores a copy of content. The write is conditional when ifMatch is
+// set. As with the service, unzip doesn't change what is stored.
+func (v *VBase) save(bucket, path string, content []byte, contentType string, ifMatch *string) (string, error) {
+	s, err := v.lock()
+	if err != nil {
+		return "", err
+	}
+	defer s.mu.Unlock()
+
+	b := s.bucket(bucket)
+	if ifMatch != nil {
+		if err := checkPrecondition("vbase", *ifMatch, b.eTag(path)); err != nil {
+			return "", err
+		}
+	}
+
+	f := &vbaseFile{append([]byte(nil), content...), contentType, hash(content)}
+	b.files[path] = f
+	delete(b.conflicts, path)
+	return f.hash, nil
+}
+
+// ListFiles returns a page of the files of a bucket, given a prefix
+func (v *VBase) ListFiles(bucket string, options *vbase.Options) (*vbase.FileListResponse, string, error) {
+	if options.Limit <= 0 {
+		options.Limit = 10
+	}
+
+	s, err := v.lock()
+	if err != nil {
+		return nil, "", err
+	}
+	defer s.mu.Unlock()
+
+	b := s.bucket(bucket)
+	var paths []string
+	for path := range b.files {
+		if strings.HasPrefix(path, options.Prefix) {
+			paths = append(paths, path)
+		}
+	}
+
+	page, next := inmem.Page(paths, options.Marker, options.Limit)
+	list := &vbase.FileListResponse{Files: []*vbase.FileListEntryResponse{}, NextMarker: next}
+	for _, path := range page {
+		list.Files = append(list.Files, &vbase.FileListEntryResponse{Path: path, Hash: b.files[path].hash})
+	}
+	return list, b.hash(), nil
+}
+
+// ListAllFiles returns a complete list of files, given a prefix
+func (v *VBase) ListAllFiles(bucket, prefix string) (*vbase.FileListResponse, string, error) {
+	options := &vbase.Options{Limit: 100, Prefix: prefix}
+
+	list, eTag, err := v.ListFiles(bucket, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for list.NextMarker != "" {
+		options.Marker = list.NextMarker
+		partialList, newETag, err := v.ListFiles(bucket, options)
+		if err != nil {
+			return nil, "", err
+		}
+
+		list.Files = append(list.Files, partialList.Files...)
+		list.NextMarker = partialList.NextMarker
+		eTag = newETag
+	}
+	return list, eTag, nil
+}
+
+// DeleteFile deletes a file
+func (v *VBase) DeleteFile(bucket, path string) error {
+	return v.delete(bucket, path, nil)
+}
+
+// DeleteFileIfMatch deletes a file only if its current ETag is eTag
+func (v *VBase) DeleteFileIfMatch(bucket, path, eTag string) error {
+	if eTag == "" {
+		return clients.ErrMissingETag
+	}
+	return v.delete(bucket, path, &eTag)
+}
+
+func (v *VBase) delete(bucket, path string, ifMatch *string) error {
+	s, err := v.lock()
+	if err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	b := s.bucket(bucket)
+	if ifMatch != nil {
+		if err := checkPrecondition("vbase", *ifMatch, b.eTag(path)); err != nil {
+			return err
+		}
+	}
+	if _, _, err := b.file(path); err != nil {
+		return err
+	}
+
+	delete(b.files, path)
+	delete(b.conflicts, path)
+	return nil
+}
@@ -0,0 +1,292 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/metadata"
+	"github.com/vtex/go-clients/workspaces"
+)
+
+var _ workspaces.Workspaces = &Workspaces{}
+
+// Workspaces is an in-memory workspaces.Workspaces of a single account, which
+// starts with its master workspace. The files and keys of each workspace are
+// held by the fakes returned by VBase and Metadata, which Reset, Clone and
+// Promote act on.
+type Workspaces struct {
+	store *workspacesStore
+	ctx   context.Context
+}
+
+type workspacesStore struct {
+	mu         sync.Mutex
+	workspaces map[string]*workspaces.Workspace
+	vbase      map[string]*vbaseStore
+	metadata   map[string]*metadataStore
+}
+
+// NewWorkspaces creates a Workspaces holding only master
+func NewWorkspaces() *Workspaces {
+	now := time.Now().UTC()
+	return &Workspaces{store: &workspacesStore{
+		workspaces: map[string]*workspaces.Workspace{
+			"master": {Name: "master", Production: true, CreatedAt: now, LastModified: now},
+		},
+		vbase:    map[string]*vbaseStore{},
+		metadata: map[string]*metadataStore{},
+	}}
+}
+
+// VBase returns a VBase holding the files of a workspace
+func (w *Workspaces) VBase(workspace string) *VBase {
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	return &VBase{store: w.store.vbaseOf(workspace)}
+}
+
+// Metadata returns a Metadata holding the keys of a workspace, which calls
+// resolver on conflicts
+func (w *Workspaces) Metadata(workspace string, resolver metadata.ConflictResolver) *Metadata {
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	return &Metadata{store: w.store.metadataOf(workspace), resolver: resolver}
+}
+
+func (s *workspacesStore) vbaseOf(workspace string) *vbaseStore {
+	store, ok := s.vbase[workspace]
+	if !ok {
+		store = &vbaseStore{buckets: map[string]*vbaseBucket{}}
+		s.vbase[workspace] = store
+	}
+	return store
+}
+
+func (s *workspacesStore) metadataOf(workspace string) *metadataStore {
+	store, ok := s.metadata[workspace]
+	if !ok {
+		store = &metadataStore{buckets: map[string]*metadataBucket{}}
+		s.metadata[workspace] = store
+	}
+	return store
+}
+
+// copyStorage replaces the files and keys of a workspace with copies of the
+// ones of another
+func (s *workspacesStore) copyStorage(from, to string) {
+	s.vbaseOf(to).copyFrom(s.vbaseOf(from))
+	s.metadataOf(to).copyFrom(s.metadataOf(from))
+}
+
+// WithContext returns a view of the fake, sharing its workspaces, whose calls
+// fail once ctx is done.
+func (w *Workspaces) WithContext(ctx context.Context) workspaces.Workspaces {
+	return &Workspaces{w.store, ctx}
+}
+
+func workspaceNotFound(name string) error {
+	return notFound("kube-router", "WorkspaceNotFound", "Workspace "+name+" not found")
+}
+
+func (w *Workspaces) lock() (*workspacesStore, error) {
+	if err := contextErr(w.ctx); err != nil {
+		return nil, err
+	}
+	w.store.mu.Lock()
+	return w.store, nil
+}
+
+func (w *Workspaces) List() ([]*workspaces.Workspace, error) {
+	s, err := w.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.workspaces))
+	for name := range s.workspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]*workspaces.Workspace, len(names))
+	for i, name := range names {
+		workspace := *s.workspaces[name]
+		list[i] = &workspace
+	}
+	return list, nil
+}
+
+func (w *Workspaces) Get(name string) (*workspaces.Workspace, error) {
+	s, err := w.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	workspace, ok := s.workspaces[name]
+	if !ok {
+		return nil, workspaceNotFound(name)
+	}
+	workspaceCopy := *workspace
+	return &workspaceCopy, nil
+}
+
+// Create creates a workspace. As with the client, a taken or invalid name
+// fails with a *workspaces.CreateError.
+func (w *Workspaces) Create(name string, options *workspaces.CreateOptions) (*workspaces.Workspace, error) {
+	if name == "" {
+		return nil, &workspaces.CreateError{Name: name, Err: workspaces.ErrInvalidName}
+	}
+
+	s, err := w.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	if _, ok := s.workspaces[name]; ok {
+		return nil, &workspaces.CreateError{
+			Name:  name,
+			Err:   workspaces.ErrWorkspaceExists,
+			Cause: clients.ResponseError{Service: "kube-router", StatusCode: http.StatusConflict, Code: "WorkspaceAlreadyExists", Message: "Workspace " + name + " already exists"},
+		}
+	}
+	if options == nil {
+		options = &workspaces.CreateOptions{}
+	}
+
+	now := time.Now().UTC()
+	workspace := &workspaces.Workspace{
+		Name:         name,
+		Production:   options.Production,
+		Weight:       options.Weight,
+		CreatedAt:    now,
+		LastModified: now,
+	}
+	s.workspaces[name] = workspace
+	workspaceCopy := *workspace
+	return &workspaceCopy, nil
+}
+
+func (w *Workspaces) Delete(name string) error {
+	s, err := w.lock()
+	if err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if _, ok := s.workspaces[name]; !ok {
+		return workspaceNotFound(name)
+	}
+	if name == "master" {
+		return clients.ResponseError{Service: "kube-router", StatusCode: http.StatusBadRequest, Code: "MasterDeletion", Message: "The master workspace can't be deleted"}
+	}
+	delete(s.workspaces, name)
+	delete(s.vbase, name)
+	delete(s.metadata, name)
+	return nil
+}
+
+// Update changes the production flag or the weight of a workspace
+func (w *Workspaces) Update(name string, patch *workspaces.Patch) (*workspaces.Workspace, error) {
+	s, err := w.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	workspace, ok := s.workspaces[name]
+	if !ok {
+		return nil, workspaceNotFound(name)
+	}
+	if patch != nil && patch.Production != nil {
+		workspace.Production = *patch.Production
+	}
+	if patch != nil && patch.Weight != nil {
+		workspace.Weight = *patch.Weight
+	}
+	workspace.LastModified = time.Now().UTC()
+
+	workspaceCopy := *workspace
+	return &workspaceCopy, nil
+}
+
+// Promote makes a production workspace the master workspace. As with the
+// stand-in, the promoted workspace takes the place of master.
+func (w *Workspaces) Promote(name string) error {
+	s, err := w.lock()
+	if err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	workspace, ok := s.workspaces[name]
+	if !ok {
+		return workspaceNotFound(name)
+	}
+	if name == "master" {
+		return clients.ResponseError{Service: "kube-router", StatusCode: http.StatusBadRequest, Code: "MasterPromotion", Message: "The master workspace can't be promoted"}
+	}
+	if !workspace.Production {
+		return clients.ResponseError{Service: "kube-router", StatusCode: http.StatusBadRequest, Code: "WorkspaceNotProduction", Message: "Only production workspaces can be promoted"}
+	}
+
+	master := s.workspaces["master"]
+	master.LastModified = time.Now().UTC()
+	s.copyStorage(name, "master")
+	delete(s.workspaces, name)
+	delete(s.vbase, name)
+	delete(s.metadata, name)
+	return nil
+}
+
+// Reset replaces the files and keys of a workspace with copies of the ones
+// of master
+func (w *Workspaces) Reset(name string) error {
+	s, err := w.lock()
+	if err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	workspace, ok := s.workspaces[name]
+	if !ok {
+		return workspaceNotFound(name)
+	}
+	if name == "master" {
+		return clients.ResponseError{Service: "kube-router", StatusCode: http.StatusBadRequest, Code: "MasterReset", Message: "The master workspace can't be reset"}
+	}
+
+	s.copyStorage("master", name)
+	workspace.LastModified = time.Now().UTC()
+	return nil
+}
+
+// Clone copies the buckets listed in options from a workspace to another,
+// creating it if needed, as the client does.
+func (w *Workspaces) Clone(from, to string, options *workspaces.CloneOptions) error {
+	if options == nil {
+		options = &workspaces.CloneOptions{}
+	}
+	if err := workspaces.PrepareClone(w, from, to, options.Create); err != nil {
+		return fmt.Errorf("Error cloning workspace %s to %s: %v", from, to, err)
+	}
+
+	src := &workspaces.Storage{VBase: w.VBase(from), Metadata: w.Metadata(from, nil)}
+	dst := &workspaces.Storage{VBase: w.VBase(to), Metadata: w.Metadata(to, nil)}
+	if w.ctx != nil {
+		src.VBase, src.Metadata = src.VBase.WithContext(w.ctx), src.Metadata.WithContext(w.ctx)
+		dst.VBase, dst.Metadata = dst.VBase.WithContext(w.ctx), dst.Metadata.WithContext(w.ctx)
+	}
+
+	if err := workspaces.CopyBuckets(src, dst, options); err != nil {
+		return fmt.Errorf("Error cloning workspace %s to %s: %v", from, to, err)
+	}
+	return nil
+}
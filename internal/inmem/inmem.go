@@ -0,0 +1,71 @@
+// Package inmem holds what the in-memory stand-in server and fakes share, so
+// that both behave the same.
+package inmem
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// DefaultPageSize is the page size of listings that don't set one
+const DefaultPageSize = 10
+
+// Hash returns a short content hash
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Page sorts names and returns the page of at most limit names starting at
+// marker, along with the marker of the next page, empty on the last one.
+func Page(names []string, marker string, limit int) ([]string, string) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	sort.Strings(names)
+	start := sort.SearchStrings(names, marker)
+	end := start + limit
+	if end >= len(names) {
+		return names[start:], ""
+	}
+	return names[start:end], names[end]
+}
+
+// Bundle returns the gzipped tarball of the files under a folder, with paths
+// relative to it, as the apps and registry services serve bundles.
+func Bundle(files map[string][]byte, folder string) ([]byte, error) {
+	prefix := strings.Trim(folder, "/") + "/"
+	var paths []string
+	for path := range files {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, path := range paths {
+		content := files[path]
+		header := &tar.Header{Name: strings.TrimPrefix(path, prefix), Mode: 0644, Size: int64(len(content))}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -1,6 +1,8 @@
 package metadata
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -28,12 +30,16 @@ type Metadata interface {
 	List(bucket string, options *Options) (*MetadataListResponse, string, error)
 	ListAll(bucket string, includeValue bool) (*MetadataListResponse, string, error)
 	Get(bucket, key string, data interface{}) (string, error)
+	GetIfNoneMatch(bucket, key string, data interface{}, eTag string) (string, bool, error)
 	Save(bucket, key string, data interface{}) (string, error)
+	SaveIfMatch(bucket, key string, data interface{}, eTag string) (string, error)
 	SaveAll(bucket string, data map[string]interface{}) (string, error)
 	DoAll(bucket string, patch MetadataPatchRequest) error
 	Delete(bucket, key string) (bool, error)
+	DeleteIfMatch(bucket, key, eTag string) (bool, error)
 	ListAllConflicts(bucket string) ([]*MetadataConflict, error)
 	ResolveConflicts(bucket string, patch MetadataPatchRequest) error
+	WithContext(ctx context.Context) Metadata
 }
 
 type ConflictResolver interface {
@@ -53,6 +59,22 @@ func NewClient(config *clients.Config, resolver ConflictResolver) Metadata {
 	return &Client{cl, resolver}
 }
 
+// New creates a Metadata client, or returns an error if config is invalid.
+// The conflict resolver is optional, as in NewClient.
+func New(config *clients.Config, resolver ConflictResolver) (Metadata, error) {
+	cl, err := clients.NewClient("kube-router", config, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{cl, resolver}, nil
+}
+
+// WithContext returns a view of the client whose requests, including the ones
+// made while resolving conflicts, are bound to ctx.
+func (cl *Client) WithContext(ctx context.Context) Metadata {
+	return &Client{clients.WithContext(cl.http, ctx), cl.conflictResolver}
+}
+
 const (
 	bucketPath      = "/buckets/%v"
 	bucketStatePath = "/buckets/%v/state"
@@ -156,6 +178,28 @@ func (cl *Client) Get(bucket, key string, data interface{}) (string, error) {
 	return res.Header.Get("ETag"), nil
 }
 
+// GetIfNoneMatch reads a key only if its ETag is no longer eTag. When it
+// didn't change, data is left untouched and modified is false.
+func (cl *Client) GetIfNoneMatch(bucket, key string, data interface{}, eTag string) (string, bool, error) {
+	req := cl.http.Get().
+		AddPath(fmt.Sprintf(metadataKeyPath, bucket, key)).
+		Use(clients.IfNoneMatch(eTag))
+	res, err := cl.performConflictResolved(bucket, req)
+	if err != nil {
+		return "", false, err
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		return eTag, false, nil
+	}
+
+	if err := res.JSON(data); err != nil {
+		return "", false, err
+	}
+
+	return res.Header.Get(clients.HeaderETag), true, nil
+}
+
 func (cl *Client) Save(bucket, key string, data interface{}) (string, error) {
 	req := cl.http.Put().
 		AddPath(fmt.Sprintf(metadataKeyPath, bucket, key)).
@@ -169,6 +213,23 @@ func (cl *Client) Save(bucket, key string, data interface{}) (string, error) {
 	return res.Header.Get("ETag"), nil
 }
 
+// SaveIfMatch saves a key only if its current ETag is eTag, or only if it
+// doesn't exist when eTag is empty. Otherwise it fails with
+// clients.ErrPreconditionFailed.
+func (cl *Client) SaveIfMatch(bucket, key string, data interface{}, eTag string) (string, error) {
+	req := cl.http.Put().
+		AddPath(fmt.Sprintf(metadataKeyPath, bucket, key)).
+		Use(clients.IfMatch(eTag)).
+		JSON(data)
+	res, err := cl.performConflictResolved(bucket, req)
+
+	if err != nil {
+		return "", err
+	}
+
+	return res.Header.Get(clients.HeaderETag), nil
+}
+
 func (cl *Client) SaveAll(bucket string, data map[string]interface{}) (string, error) {
 	req := cl.http.Put().
 		AddPath(fmt.Sprintf(metadataPath, bucket)).
@@ -188,7 +249,7 @@ func (cl *Client) Delete(bucket, key string) (bool, error) {
 	_, err := cl.performConflictResolved(bucket, req)
 
 	if err != nil {
-		if err, ok := err.(clients.ResponseError); ok && err.StatusCode == http.StatusNotFound {
+		if errors.Is(err, clients.ErrNotFound) {
 			return false, nil
 		}
 		return false, err
@@ -197,6 +258,25 @@ func (cl *Client) Delete(bucket, key string) (bool, error) {
 	return true, nil
 }
 
+// DeleteIfMatch deletes a key only if its current ETag is eTag. Otherwise it
+// fails with clients.ErrPreconditionFailed, which is also the case of a key
+// that is already gone. Unlike Delete, it never returns false without an
+// error. An empty eTag fails with clients.ErrMissingETag.
+func (cl *Client) DeleteIfMatch(bucket, key, eTag string) (bool, error) {
+	if eTag == "" {
+		return false, clients.ErrMissingETag
+	}
+
+	req := cl.http.Delete().
+		AddPath(fmt.Sprintf(metadataKeyPath, bucket, key)).
+		Use(clients.IfMatch(eTag))
+	if _, err := cl.performConflictResolved(bucket, req); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (cl *Client) DoAll(bucket string, patch MetadataPatchRequest) error {
 	toSave := map[string]interface{}{}
 	// not to block goroutines, assume at most one error per operation
@@ -293,10 +373,7 @@ func (cl *Client) performConflictResolved(bucket string, req *gentleman.Request)
 }
 
 func isConflict(err error) bool {
-	if respErr, ok := err.(clients.ResponseError); ok && respErr.StatusCode == http.StatusConflict {
-		return true
-	}
-	return false
+	return errors.Is(err, clients.ErrConflict)
 }
 
 func mapKeys(m map[string]interface{}) []string {
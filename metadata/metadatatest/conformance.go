@@ -0,0 +1,487 @@
+// Package metadatatest checks that implementations of metadata.Metadata
+// behave as the client does against the service, so that fakes and
+// alternative backends can't drift from it.
+package metadatatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/metadata"
+)
+
+// Factory returns a Metadata without keys that calls resolver on conflicts,
+// along with a function that adds a conflict to a bucket. The function may be
+// nil when the backend can't simulate conflicts, in which case conflicts
+// aren't checked.
+type Factory func(t *testing.T, resolver metadata.ConflictResolver) (m metadata.Metadata, addConflict func(bucket string, c *metadata.MetadataConflict))
+
+const bucket = "conformance"
+
+type value struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// RunConformance runs the conformance suite as subtests of t, each on a new
+// Metadata from factory.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, factory Factory)
+	}{
+		{"GetMissingKey", testGetMissingKey},
+		{"Save", testSave},
+		{"ETagChangesOnWrite", testETagChangesOnWrite},
+		{"GetIfNoneMatch", testGetIfNoneMatch},
+		{"SaveIfMatch", testSaveIfMatch},
+		{"Delete", testDelete},
+		{"DeleteIfMatch", testDeleteIfMatch},
+		{"List", testList},
+		{"ListAll", testListAll},
+		{"SaveAll", testSaveAll},
+		{"DoAll", testDoAll},
+		{"Bucket", testBucket},
+		{"Conflicts", testConflicts},
+		{"ConflictResolver", testConflictResolver},
+		{"WithContext", testWithContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, factory)
+		})
+	}
+}
+
+func testGetMissingKey(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+
+	var v value
+	if _, err := m.Get(bucket, "missing", &v); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("Get of a missing key: got %v, want clients.ErrNotFound", err)
+	}
+	if _, _, err := m.GetIfNoneMatch(bucket, "missing", &v, "etag"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("GetIfNoneMatch of a missing key: got %v, want clients.ErrNotFound", err)
+	}
+}
+
+func testSave(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+
+	eTag, err := m.Save(bucket, "key", &value{"one", 1})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if eTag == "" {
+		t.Error("Save returned no ETag")
+	}
+	checkKey(t, m, "key", value{"one", 1}, eTag)
+}
+
+func testETagChangesOnWrite(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+
+	first := save(t, m, "key", value{"one", 1})
+	second := save(t, m, "key", value{"two", 2})
+	if first == second {
+		t.Errorf("ETag %q didn't change when the value did", first)
+	}
+	checkKey(t, m, "key", value{"two", 2}, second)
+}
+
+func testGetIfNoneMatch(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+	eTag := save(t, m, "key", value{"one", 1})
+
+	v := value{"untouched", 0}
+	current, modified, err := m.GetIfNoneMatch(bucket, "key", &v, eTag)
+	if err != nil {
+		t.Fatalf("GetIfNoneMatch: %v", err)
+	}
+	if modified || current != eTag || v.Name != "untouched" {
+		t.Errorf("GetIfNoneMatch of an unchanged key: got modified %v, ETag %q, value %+v, want false, %q and an untouched value", modified, current, v, eTag)
+	}
+
+	newETag := save(t, m, "key", value{"two", 2})
+	current, modified, err = m.GetIfNoneMatch(bucket, "key", &v, eTag)
+	if err != nil {
+		t.Fatalf("GetIfNoneMatch: %v", err)
+	}
+	if !modified || current != newETag || v != (value{"two", 2}) {
+		t.Errorf("GetIfNoneMatch of a changed key: got modified %v, ETag %q, value %+v, want true, %q, %+v", modified, current, v, newETag, value{"two", 2})
+	}
+}
+
+func testSaveIfMatch(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+
+	eTag, err := m.SaveIfMatch(bucket, "key", &value{"one", 1}, "")
+	if err != nil {
+		t.Fatalf("SaveIfMatch of a new key: %v", err)
+	}
+
+	if _, err := m.SaveIfMatch(bucket, "key", &value{"two", 2}, ""); !errors.Is(err, clients.ErrPreconditionFailed) {
+		t.Errorf("SaveIfMatch without ETag of an existing key: got %v, want clients.ErrPreconditionFailed", err)
+	}
+
+	newETag, err := m.SaveIfMatch(bucket, "key", &value{"two", 2}, eTag)
+	if err != nil {
+		t.Fatalf("SaveIfMatch with the current ETag: %v", err)
+	}
+
+	if _, err := m.SaveIfMatch(bucket, "key", &value{"three", 3}, eTag); !errors.Is(err, clients.ErrPreconditionFailed) {
+		t.Errorf("SaveIfMatch with a stale ETag: got %v, want clients.ErrPreconditionFailed", err)
+	}
+	checkKey(t, m, "key", value{"two", 2}, newETag)
+}
+
+func testDelete(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+	save(t, m, "key", value{"one", 1})
+
+	deleted, err := m.Delete(bucket, "key")
+	if err != nil || !deleted {
+		t.Fatalf("Delete of an existing key: got %v, %v, want true, nil", deleted, err)
+	}
+	var v value
+	if _, err := m.Get(bucket, "key", &v); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("Get of a deleted key: got %v, want clients.ErrNotFound", err)
+	}
+
+	deleted, err = m.Delete(bucket, "key")
+	if err != nil || deleted {
+		t.Errorf("Delete of a missing key: got %v, %v, want false, nil", deleted, err)
+	}
+}
+
+func testDeleteIfMatch(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+	stale := save(t, m, "key", value{"one", 1})
+	eTag := save(t, m, "key", value{"two", 2})
+
+	if _, err := m.DeleteIfMatch(bucket, "key", stale); !errors.Is(err, clients.ErrPreconditionFailed) {
+		t.Errorf("DeleteIfMatch with a stale ETag: got %v, want clients.ErrPreconditionFailed", err)
+	}
+	checkKey(t, m, "key", value{"two", 2}, eTag)
+
+	if _, err := m.DeleteIfMatch(bucket, "key", ""); !errors.Is(err, clients.ErrMissingETag) {
+		t.Errorf("DeleteIfMatch without ETag: got %v, want clients.ErrMissingETag", err)
+	}
+	checkKey(t, m, "key", value{"two", 2}, eTag)
+
+	deleted, err := m.DeleteIfMatch(bucket, "key", eTag)
+	if err != nil || !deleted {
+		t.Fatalf("DeleteIfMatch with the current ETag: got %v, %v, want true, nil", deleted, err)
+	}
+
+	deleted, err = m.DeleteIfMatch(bucket, "key", eTag)
+	if !errors.Is(err, clients.ErrPreconditionFailed) || deleted {
+		t.Errorf("DeleteIfMatch of a deleted key: got %v, %v, want false, clients.ErrPreconditionFailed", deleted, err)
+	}
+}
+
+func testList(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+
+	var want []string
+	for i := 11; i >= 0; i-- {
+		key := fmt.Sprintf("key%02d", i)
+		save(t, m, key, value{key, i})
+		want = append([]string{key}, want...)
+	}
+
+	list, _, err := m.List(bucket, &metadata.Options{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list.Data) != 10 || list.NextMarker == "" {
+		t.Errorf("List without limit: got %d keys and marker %q, want 10 keys and a marker", len(list.Data), list.NextMarker)
+	}
+	for _, entry := range list.Data {
+		if len(entry.Value) != 0 && string(entry.Value) != "null" {
+			t.Errorf("List without values: got value %s for key %s", entry.Value, entry.Key)
+		}
+	}
+
+	var got []string
+	options := &metadata.Options{Limit: 5, IncludeValue: true}
+	for pages := 1; ; pages++ {
+		list, _, err := m.List(bucket, options)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(list.Data) > 5 {
+			t.Errorf("List with limit 5: got %d keys", len(list.Data))
+		}
+		for _, entry := range list.Data {
+			if entry.Hash == "" {
+				t.Errorf("List: key %s has no hash", entry.Key)
+			}
+			if !strings.Contains(string(entry.Value), entry.Key) {
+				t.Errorf("List with values: got value %s for key %s", entry.Value, entry.Key)
+			}
+			got = append(got, entry.Key)
+		}
+		if list.NextMarker == "" {
+			break
+		}
+		if pages > len(want) {
+			t.Fatalf("List: marker %q doesn't progress", list.NextMarker)
+		}
+		options.Marker = list.NextMarker
+	}
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("List pages: got %v, want %v", got, want)
+	}
+}
+
+func testListAll(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+
+	list, _, err := m.ListAll(bucket, false)
+	if err != nil {
+		t.Fatalf("ListAll: %v", err)
+	}
+	if len(list.Data) != 0 {
+		t.Errorf("ListAll of an empty bucket: got %d keys, want none", len(list.Data))
+	}
+
+	save(t, m, "b", value{"b", 2})
+	save(t, m, "a", value{"a", 1})
+	list, _, err = m.ListAll(bucket, true)
+	if err != nil {
+		t.Fatalf("ListAll: %v", err)
+	}
+	if keys(list) != "a,b" || list.NextMarker != "" {
+		t.Errorf("ListAll: got keys %s and marker %q, want a,b and no marker", keys(list), list.NextMarker)
+	}
+}
+
+func testSaveAll(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+
+	_, err := m.SaveAll(bucket, map[string]interface{}{
+		"a": &value{"a", 1},
+		"b": &value{"b", 2},
+	})
+	if err != nil {
+		t.Fatalf("SaveAll: %v", err)
+	}
+	checkKey(t, m, "a", value{"a", 1}, "")
+	checkKey(t, m, "b", value{"b", 2}, "")
+}
+
+func testDoAll(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+	save(t, m, "removed", value{"removed", 0})
+	save(t, m, "replaced", value{"old", 0})
+
+	err := m.DoAll(bucket, metadata.MetadataPatchRequest{
+		{Type: metadata.OperationTypeRemove, Key: "removed"},
+		{Type: metadata.OperationTypeRemove, Key: "missing"},
+		{Type: metadata.OperationTypeReplace, Key: "replaced", Value: &value{"new", 1}},
+		{Type: metadata.OperationTypeAdd, Key: "added", Value: &value{"added", 2}},
+	})
+	if err != nil {
+		t.Fatalf("DoAll: %v", err)
+	}
+
+	list, _, err := m.ListAll(bucket, false)
+	if err != nil {
+		t.Fatalf("ListAll: %v", err)
+	}
+	if keys(list) != "added,replaced" {
+		t.Errorf("DoAll: got keys %s, want added,replaced", keys(list))
+	}
+	checkKey(t, m, "replaced", value{"new", 1}, "")
+}
+
+func testBucket(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+
+	if err := m.SetBucketState(bucket, "safe"); err != nil {
+		t.Fatalf("SetBucketState: %v", err)
+	}
+	before, _, err := m.GetBucket(bucket)
+	if err != nil {
+		t.Fatalf("GetBucket: %v", err)
+	}
+
+	save(t, m, "key", value{"one", 1})
+	after, _, err := m.GetBucket(bucket)
+	if err != nil {
+		t.Fatalf("GetBucket: %v", err)
+	}
+	if after.Hash == before.Hash {
+		t.Errorf("GetBucket: hash %q didn't change when a key was saved", after.Hash)
+	}
+}
+
+func testConflicts(t *testing.T, factory Factory) {
+	m, addConflict := factory(t, nil)
+	if addConflict == nil {
+		t.Skip("the backend can't simulate conflicts")
+	}
+
+	conflicts, err := m.ListAllConflicts(bucket)
+	if err != nil {
+		t.Fatalf("ListAllConflicts: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("ListAllConflicts of a bucket without conflicts: got %d, want none", len(conflicts))
+	}
+
+	save(t, m, "key", value{"base", 0})
+	addConflict(bucket, newConflict("key"))
+	addConflict(bucket, newConflict("other"))
+
+	// Without a resolver, conflicts don't fail other calls.
+	checkKey(t, m, "key", value{"base", 0}, "")
+
+	conflicts, err = m.ListAllConflicts(bucket)
+	if err != nil {
+		t.Fatalf("ListAllConflicts: %v", err)
+	}
+	if len(conflicts) != 2 || conflicts[0].Key != "key" || conflicts[0].Mine == nil {
+		t.Fatalf("ListAllConflicts: got %+v, want the conflicts of key and other", conflicts)
+	}
+
+	err = m.ResolveConflicts(bucket, metadata.MetadataPatchRequest{
+		{Type: metadata.OperationTypeReplace, Key: "key", Value: &value{"merged", 3}},
+	})
+	if err != nil {
+		t.Fatalf("ResolveConflicts: %v", err)
+	}
+	checkKey(t, m, "key", value{"merged", 3}, "")
+
+	conflicts, err = m.ListAllConflicts(bucket)
+	if err != nil {
+		t.Fatalf("ListAllConflicts: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Key != "other" {
+		t.Errorf("ListAllConflicts after resolving key: got %+v, want the conflict of other", conflicts)
+	}
+}
+
+// resolver replaces every conflicted key with its own value, or fails to
+// resolve them when resolved is false.
+type resolver struct {
+	resolved bool
+	err      error
+	calls    int
+}
+
+func (r *resolver) Resolve(m metadata.Metadata, bucket string) (bool, error) {
+	r.calls++
+	if r.err != nil || !r.resolved {
+		return false, r.err
+	}
+
+	conflicts, err := m.ListAllConflicts(bucket)
+	if err != nil {
+		return false, err
+	}
+	var patch metadata.MetadataPatchRequest
+	for _, c := range conflicts {
+		patch = append(patch, &metadata.PatchOperation{Type: metadata.OperationTypeReplace, Key: c.Key, Value: &value{"mine", 2}})
+	}
+	return true, m.ResolveConflicts(bucket, patch)
+}
+
+func testConflictResolver(t *testing.T, factory Factory) {
+	r := &resolver{resolved: true}
+	m, addConflict := factory(t, r)
+	if addConflict == nil {
+		t.Skip("the backend can't simulate conflicts")
+	}
+
+	save(t, m, "key", value{"base", 0})
+	addConflict(bucket, newConflict("key"))
+	checkKey(t, m, "key", value{"mine", 2}, "")
+	if r.calls != 1 {
+		t.Errorf("Get of a conflicted bucket: resolver called %d times, want once", r.calls)
+	}
+
+	t.Run("Unresolved", func(t *testing.T) {
+		r := &resolver{}
+		m, addConflict := factory(t, r)
+		addConflict(bucket, newConflict("key"))
+
+		var v value
+		if _, err := m.Get(bucket, "key", &v); !errors.Is(err, clients.ErrConflict) {
+			t.Errorf("Get of a conflicted bucket when conflicts aren't resolved: got %v, want clients.ErrConflict", err)
+		}
+	})
+
+	t.Run("ResolverError", func(t *testing.T) {
+		r := &resolver{err: errors.New("resolver failed")}
+		m, addConflict := factory(t, r)
+		addConflict(bucket, newConflict("key"))
+
+		if _, err := m.Save(bucket, "key", &value{"one", 1}); err == nil || !strings.Contains(err.Error(), "resolver failed") {
+			t.Errorf("Save to a conflicted bucket when the resolver fails: got %v, want its error", err)
+		}
+	})
+}
+
+func testWithContext(t *testing.T, factory Factory) {
+	m, _ := factory(t, nil)
+	save(t, m, "key", value{"one", 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var v value
+	if _, err := m.WithContext(ctx).Get(bucket, "key", &v); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get with a canceled context: got %v, want context.Canceled", err)
+	}
+	checkKey(t, m, "key", value{"one", 1}, "")
+}
+
+func newConflict(key string) *metadata.MetadataConflict {
+	return &metadata.MetadataConflict{
+		Key:    key,
+		Base:   &metadata.MetadataConflictEntry{Value: []byte(`{"name":"base","count":0}`)},
+		Mine:   &metadata.MetadataConflictEntry{Value: []byte(`{"name":"mine","count":2}`)},
+		Master: &metadata.MetadataConflictEntry{Value: []byte(`{"name":"master","count":1}`)},
+	}
+}
+
+func save(t *testing.T, m metadata.Metadata, key string, v value) string {
+	t.Helper()
+	eTag, err := m.Save(bucket, key, &v)
+	if err != nil {
+		t.Fatalf("Save %s: %v", key, err)
+	}
+	return eTag
+}
+
+// checkKey checks the value of a key, and its ETag unless eTag is empty
+func checkKey(t *testing.T, m metadata.Metadata, key string, want value, eTag string) {
+	t.Helper()
+	var got value
+	current, err := m.Get(bucket, key, &got)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	if got != want {
+		t.Errorf("Get %s: got %+v, want %+v", key, got, want)
+	}
+	if eTag != "" && current != eTag {
+		t.Errorf("Get %s: got ETag %q, want %q", key, current, eTag)
+	}
+}
+
+func keys(list *metadata.MetadataListResponse) string {
+	keys := make([]string, len(list.Data))
+	for i, entry := range list.Data {
+		keys[i] = entry.Key
+	}
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,31 @@
+package metadatatest
+
+import (
+	"testing"
+
+	"github.com/vtex/go-clients/fakes"
+	"github.com/vtex/go-clients/metadata"
+	"github.com/vtex/go-clients/standin"
+)
+
+func TestFakeConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T, resolver metadata.ConflictResolver) (metadata.Metadata, func(string, *metadata.MetadataConflict)) {
+		m := fakes.NewMetadata(resolver)
+		return m, m.AddConflict
+	})
+}
+
+func TestClientConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T, resolver metadata.ConflictResolver) (metadata.Metadata, func(string, *metadata.MetadataConflict)) {
+		s := standin.New()
+		t.Cleanup(s.Close)
+
+		m, err := metadata.New(s.Config("account", "workspace"), resolver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m, func(bucket string, c *metadata.MetadataConflict) {
+			s.AddMetadataConflict("account", "workspace", bucket, c)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package standin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/vtex/go-clients/apps"
+	"github.com/vtex/go-clients/internal/inmem"
+)
+
+type installedApp struct {
+	manifest *apps.ActiveApp
+	files    map[string][]byte
+}
+
+type publishedApp struct {
+	manifest *apps.PublishedApp
+	files    map[string][]byte
+}
+
+// InstallApp installs an app in a workspace, replacing any other version of
+// it. Its files are served by path, and bundles are the gzipped tarballs of
+// the files under a folder.
+func (s *Server) InstallApp(account, workspace string, app *apps.ActiveApp, files map[string][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := app.Vendor + "." + app.Name
+	if app.ID == "" {
+		app.ID = name + "@" + app.Version
+	}
+	s.account(account).workspace(workspace).apps[name] = &installedApp{app, files}
+}
+
+// PublishApp publishes an app version to the registry of an account
+func (s *Server) PublishApp(account string, app *apps.PublishedApp, files map[string][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := app.Vendor + "." + app.Name + "@" + app.Version
+	if app.ID == "" {
+		app.ID = id
+	}
+	s.account(account).registry[id] = &publishedApp{app, files}
+}
+
+// SetDependencies sets the dependencies reported for a workspace
+func (s *Server) SetDependencies(account, workspace string, dependencies map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account(account).workspace(workspace).dependencies = dependencies
+}
+
+// serveApps answers the apps routes under /{account}/{workspace}. The parent
+// of the requests is ignored.
+func (s *Server) serveApps(w http.ResponseWriter, r *http.Request, ws *workspace, segments []string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+
+	if segments[0] == "dependencies" {
+		if len(segments) != 1 {
+			writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+			return
+		}
+		writeJSON(w, http.StatusOK, "", ws.dependencies)
+		return
+	}
+
+	if len(segments) < 2 {
+		writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+		return
+	}
+
+	name := strings.SplitN(segments[1], "@", 2)[0]
+	app, ok := ws.apps[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "AppNotFound", "App "+segments[1]+" is not installed")
+		return
+	}
+	serveAppRoute(w, r, app.manifest, app.files, segments[2:])
+}
+
+// serveRegistry answers the registry routes, as in
+// /{account}/master/registry/{name}/{version}
+func (s *Server) serveRegistry(w http.ResponseWriter, r *http.Request, acc *account, segments []string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	if len(segments) < 2 {
+		writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+		return
+	}
+
+	id := segments[0] + "@" + segments[1]
+	app, ok := acc.registry[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "AppNotFound", "App "+id+" is not published")
+		return
+	}
+	serveAppRoute(w, r, app.manifest, app.files, segments[2:])
+}
+
+// serveAppRoute answers the routes shared by installed and published apps:
+// the manifest, the file list, a file and a bundle.
+func serveAppRoute(w http.ResponseWriter, r *http.Request, manifest interface{}, files map[string][]byte, segments []string) {
+	switch {
+	case len(segments) == 0:
+		buf, _ := json.Marshal(manifest)
+		writeJSON(w, http.StatusOK, hash(buf), manifest)
+	case segments[0] == "files" && len(segments) == 1:
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		list := &apps.FileList{Files: []*apps.File{}}
+		for _, path := range paths {
+			list.Files = append(list.Files, &apps.File{Path: path, Hash: hash(files[path])})
+		}
+		writeJSON(w, http.StatusOK, "", list)
+	case segments[0] == "files":
+		path := strings.Join(segments[1:], "/")
+		content, ok := files[path]
+		if !ok {
+			writeError(w, http.StatusNotFound, "FileNotFound", "File "+path+" not found")
+			return
+		}
+		w.Header().Set("ETag", hash(content))
+		w.Write(content)
+	case segments[0] == "bundle":
+		content, err := inmem.Bundle(files, strings.Join(segments[1:], "/"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "BundleError", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("ETag", hash(content))
+		w.Write(content)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+	}
+}
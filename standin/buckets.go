@@ -0,0 +1,377 @@
+package standin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vtex/go-clients/internal/inmem"
+	"github.com/vtex/go-clients/metadata"
+	"github.com/vtex/go-clients/vbase"
+)
+
+const (
+	conflictResolutionHeader = "X-Conflict-Resolution"
+	detectConflictsHeader    = "X-Vtex-Detect-Conflicts"
+)
+
+// bucket holds both the vbase files and the metadata keys of a bucket, as
+// the two services share the /buckets routes.
+type bucket struct {
+	state             string
+	files             map[string]*file
+	fileConflicts     map[string]*vbase.Conflict
+	metadata          map[string]json.RawMessage
+	metadataConflicts []*metadata.MetadataConflict
+}
+
+type file struct {
+	content     []byte
+	contentType string
+	hash        string
+}
+
+func (ws *workspace) bucket(name string) *bucket {
+	b, ok := ws.buckets[name]
+	if !ok {
+		b = &bucket{
+			files:         map[string]*file{},
+			fileConflicts: map[string]*vbase.Conflict{},
+			metadata:      map[string]json.RawMessage{},
+		}
+		ws.buckets[name] = b
+	}
+	return b
+}
+
+// clone copies a bucket. Files and values are replaced rather than changed
+// on writes, so they are shared.
+func (b *bucket) clone() *bucket {
+	c := &bucket{
+		state:             b.state,
+		files:             make(map[string]*file, len(b.files)),
+		fileConflicts:     make(map[string]*vbase.Conflict, len(b.fileConflicts)),
+		metadata:          make(map[string]json.RawMessage, len(b.metadata)),
+		metadataConflicts: append([]*metadata.MetadataConflict(nil), b.metadataConflicts...),
+	}
+	for path, f := range b.files {
+		c.files[path] = f
+	}
+	for path, conflict := range b.fileConflicts {
+		c.fileConflicts[path] = conflict
+	}
+	for key, value := range b.metadata {
+		c.metadata[key] = value
+	}
+	return c
+}
+
+// hash changes whenever a file or key of the bucket does
+func (b *bucket) hash() string {
+	var entries []string
+	for path, f := range b.files {
+		entries = append(entries, "file:"+path+":"+f.hash)
+	}
+	for key, value := range b.metadata {
+		entries = append(entries, "key:"+key+":"+hash(value))
+	}
+	sort.Strings(entries)
+	return hash([]byte(b.state + "\n" + strings.Join(entries, "\n")))
+}
+
+// File returns the content of a vbase file, if it exists
+func (s *Server) File(account, workspace, bucket, path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.account(account).workspace(workspace).bucket(bucket).files[path]
+	if !ok {
+		return nil, false
+	}
+	return f.content, true
+}
+
+// AddFileConflict makes reads of a vbase file that ask for conflicts to be
+// merged fail with 409 and c, until the file is saved again.
+func (s *Server) AddFileConflict(account, workspace, bucket, path string, c *vbase.Conflict) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account(account).workspace(workspace).bucket(bucket).fileConflicts[path] = c
+}
+
+// AddMetadataConflict makes the metadata requests of a bucket that detect
+// conflicts fail with 409, until c is resolved.
+func (s *Server) AddMetadataConflict(account, workspace, bucket string, c *metadata.MetadataConflict) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.account(account).workspace(workspace).bucket(bucket)
+	b.metadataConflicts = append(b.metadataConflicts, c)
+}
+
+// serveBuckets answers the vbase and metadata routes under
+// /{account}/{workspace}/buckets
+func (s *Server) serveBuckets(w http.ResponseWriter, r *http.Request, ws *workspace, segments []string) {
+	if len(segments) == 0 || segments[0] == "" {
+		writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+		return
+	}
+
+	b := ws.bucket(segments[0])
+	switch {
+	case len(segments) == 1:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, b.hash(), &vbase.BucketResponse{Hash: b.hash(), State: b.state})
+	case segments[1] == "state" && len(segments) == 2:
+		if r.Method != http.MethodPut {
+			methodNotAllowed(w, r)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidBody", err.Error())
+			return
+		}
+		// The clients send the state as is, as gentleman doesn't encode
+		// strings passed as JSON.
+		state := string(body)
+		json.Unmarshal(body, &state)
+		b.state = state
+		w.WriteHeader(http.StatusNoContent)
+	case segments[1] == "files" && len(segments) == 2:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+		b.listFiles(w, r)
+	case segments[1] == "files":
+		b.serveFile(w, r, strings.Join(segments[2:], "/"))
+	case segments[1] == "metadata" && len(segments) == 2:
+		b.serveMetadata(w, r)
+	case segments[1] == "metadata" && len(segments) == 3:
+		b.serveMetadataKey(w, r, segments[2])
+	case segments[1] == "conflicts" && len(segments) == 2:
+		b.serveConflicts(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+	}
+}
+
+func (b *bucket) listFiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+
+	var paths []string
+	for path := range b.files {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+
+	limit, _ := strconv.Atoi(query.Get("_limit"))
+	page, next := inmem.Page(paths, query.Get("_next"), limit)
+	list := &vbase.FileListResponse{Files: []*vbase.FileListEntryResponse{}, NextMarker: next}
+	for _, path := range page {
+		list.Files = append(list.Files, &vbase.FileListEntryResponse{Path: path, Hash: b.files[path].hash})
+	}
+	writeJSON(w, http.StatusOK, b.hash(), list)
+}
+
+func (b *bucket) serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	f, exists := b.files[path]
+	var eTag string
+	if exists {
+		eTag = f.hash
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if conflict, ok := b.fileConflicts[path]; ok && strings.EqualFold(r.Header.Get(conflictResolutionHeader), "merge") {
+			writeJSON(w, http.StatusConflict, eTag, conflict)
+			return
+		}
+		if !exists {
+			writeError(w, http.StatusNotFound, "FileNotFound", "File "+path+" not found")
+			return
+		}
+		if notModified(w, r, eTag) {
+			return
+		}
+		if f.contentType != "" {
+			w.Header().Set("Content-Type", f.contentType)
+		}
+		w.Header().Set("ETag", eTag)
+		w.Write(f.content)
+	case http.MethodPut:
+		if !checkPrecondition(w, r, eTag) {
+			return
+		}
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidBody", err.Error())
+			return
+		}
+		// Files are stored as sent, unzip=true included.
+		saved := &file{content: content, contentType: r.Header.Get("Content-Type"), hash: hash(content)}
+		b.files[path] = saved
+		delete(b.fileConflicts, path)
+		w.Header().Set("ETag", saved.hash)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if !checkPrecondition(w, r, eTag) {
+			return
+		}
+		if !exists {
+			writeError(w, http.StatusNotFound, "FileNotFound", "File "+path+" not found")
+			return
+		}
+		delete(b.files, path)
+		delete(b.fileConflicts, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+// conflicted answers 409 and returns true when a metadata request asks for
+// conflicts to be detected and the bucket has some.
+func (b *bucket) conflicted(w http.ResponseWriter, r *http.Request) bool {
+	if len(b.metadataConflicts) > 0 && r.Header.Get(detectConflictsHeader) == "true" {
+		writeError(w, http.StatusConflict, "Conflict", "The bucket has unresolved conflicts")
+		return true
+	}
+	return false
+}
+
+func (b *bucket) serveMetadata(w http.ResponseWriter, r *http.Request) {
+	if b.conflicted(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		keys := make([]string, 0, len(b.metadata))
+		for key := range b.metadata {
+			keys = append(keys, key)
+		}
+
+		limit, _ := strconv.Atoi(query.Get("_limit"))
+		page, next := inmem.Page(keys, query.Get("_marker"), limit)
+		list := &metadata.MetadataListResponse{Data: []*metadata.MetadataResponseEntry{}, NextMarker: next}
+		for _, key := range page {
+			entry := &metadata.MetadataResponseEntry{Key: key, Hash: hash(b.metadata[key])}
+			if query.Get("value") == "true" {
+				entry.Value = b.metadata[key]
+			}
+			list.Data = append(list.Data, entry)
+		}
+		writeJSON(w, http.StatusOK, b.hash(), list)
+	case http.MethodPut:
+		var values map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidBody", "The body must be a JSON object")
+			return
+		}
+		for key, value := range values {
+			b.metadata[key] = value
+		}
+		w.Header().Set("ETag", b.hash())
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+func (b *bucket) serveMetadataKey(w http.ResponseWriter, r *http.Request, key string) {
+	if b.conflicted(w, r) {
+		return
+	}
+
+	value, exists := b.metadata[key]
+	var eTag string
+	if exists {
+		eTag = hash(value)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			writeError(w, http.StatusNotFound, "KeyNotFound", "Key "+key+" not found")
+			return
+		}
+		if notModified(w, r, eTag) {
+			return
+		}
+		writeJSON(w, http.StatusOK, eTag, value)
+	case http.MethodPut:
+		if !checkPrecondition(w, r, eTag) {
+			return
+		}
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidBody", "The body must be JSON")
+			return
+		}
+		b.metadata[key] = body
+		w.Header().Set("ETag", hash(body))
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if !checkPrecondition(w, r, eTag) {
+			return
+		}
+		if !exists {
+			writeError(w, http.StatusNotFound, "KeyNotFound", "Key "+key+" not found")
+			return
+		}
+		delete(b.metadata, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+func (b *bucket) serveConflicts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, "", &metadata.MetadataConflictListResponse{Data: b.metadataConflicts})
+	case http.MethodPost:
+		var patch metadata.MetadataPatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidBody", "The body must be a JSON patch")
+			return
+		}
+
+		resolved := map[string]bool{}
+		for _, op := range patch {
+			switch op.Type {
+			case metadata.OperationTypeAdd, metadata.OperationTypeReplace:
+				value, err := json.Marshal(op.Value)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "InvalidBody", err.Error())
+					return
+				}
+				b.metadata[op.Key] = value
+			case metadata.OperationTypeRemove:
+				delete(b.metadata, op.Key)
+			}
+			resolved[op.Key] = true
+		}
+
+		var remaining []*metadata.MetadataConflict
+		for _, c := range b.metadataConflicts {
+			if !resolved[c.Key] {
+				remaining = append(remaining, c)
+			}
+		}
+		b.metadataConflicts = remaining
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
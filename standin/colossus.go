@@ -0,0 +1,66 @@
+package standin
+
+import (
+	"io/ioutil"
+	"net/http"
+)
+
+// Event is an event received by the colossus routes
+type Event struct {
+	Account   string
+	Workspace string
+	Sender    string
+	Subject   string
+	Key       string
+	Body      []byte
+}
+
+// Log is a log entry received by the colossus routes
+type Log struct {
+	Account   string
+	Workspace string
+	Sender    string
+	Subject   string
+	Level     string
+	Body      []byte
+}
+
+// Events returns the events received so far, in order
+func (s *Server) Events() []*Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Event(nil), s.events...)
+}
+
+// Logs returns the log entries received so far, in order
+func (s *Server) Logs() []*Log {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Log(nil), s.logs...)
+}
+
+// serveColossus answers the colossus routes, as in
+// /{account}/{workspace}/events/{sender}/{subject}/{key}
+func (s *Server) serveColossus(w http.ResponseWriter, r *http.Request, account, workspace string, segments []string) {
+	if len(segments) != 4 {
+		writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidBody", err.Error())
+		return
+	}
+
+	if segments[0] == "events" {
+		s.events = append(s.events, &Event{account, workspace, segments[1], segments[2], segments[3], body})
+	} else {
+		s.logs = append(s.logs, &Log{account, workspace, segments[1], segments[2], segments[3], body})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
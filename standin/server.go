@@ -0,0 +1,259 @@
+// Package standin runs an in-memory stand-in of the VTEX IO services called
+// by the clients, for integration tests that must run offline. A single
+// Server answers the routes of kube-router (workspaces and metadata), vbase,
+// apps, the registry and colossus, so every client can use its URL as
+// Config.Endpoint.
+//
+// Accounts exist as soon as they are used, each with a master workspace.
+// Faults can be injected to make requests fail with any status, and
+// conflicts can be added to vbase files and metadata buckets.
+package standin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/internal/inmem"
+)
+
+// Server is the stand-in, listening on a local httptest server
+type Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	accounts map[string]*account
+	faults   []*Fault
+	events   []*Event
+	logs     []*Log
+}
+
+type account struct {
+	workspaces map[string]*workspace
+	registry   map[string]*publishedApp
+}
+
+type workspace struct {
+	// created is false for workspaces only used by the routes of services
+	// bound to a workspace, which the workspace routes don't list
+	created      bool
+	production   bool
+	weight       int
+	createdAt    time.Time
+	lastModified time.Time
+	buckets      map[string]*bucket
+	apps         map[string]*installedApp
+	dependencies map[string][]string
+}
+
+// New starts a Server. It must be closed once the test is done.
+func New() *Server {
+	s := &Server{accounts: map[string]*account{}}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Config returns a config for clients of account and workspace that talk to
+// the server.
+func (s *Server) Config(account, workspace string) *clients.Config {
+	return &clients.Config{
+		Account:        account,
+		Workspace:      workspace,
+		Endpoint:       s.URL,
+		AuthToken:      "standin",
+		UserAgent:      "standin",
+		RequestContext: clients.NewRequestContext(nil),
+	}
+}
+
+// Fault makes the requests it matches fail without touching the state
+type Fault struct {
+	// Method of the requests to fail, or any method if empty
+	Method string
+	// PathPrefix of the requests to fail, as in "/account/master/buckets"
+	PathPrefix string
+	// Status of the error response, as in 409 or 503
+	Status  int
+	Code    string
+	Message string
+	// Times is how many requests fail before the fault is cleared, or zero
+	// to fail requests until ClearFaults
+	Times int
+}
+
+// Inject adds a fault, checked before the ones added earlier
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append([]*Fault{&f}, s.faults...)
+}
+
+// ClearFaults removes every fault
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+func (s *Server) fault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if (f.Method == "" || f.Method == r.Method) && strings.HasPrefix(r.URL.Path, f.PathPrefix) {
+			if f.Times > 0 {
+				if f.Times--; f.Times == 0 {
+					s.faults = append(s.faults[:i], s.faults[i+1:]...)
+				}
+			}
+			return f
+		}
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Read the body first, so that a slow upload doesn't hold the lock
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidBody", err.Error())
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f := s.fault(r); f != nil {
+		code := f.Code
+		if code == "" {
+			code = "injected"
+		}
+		writeError(w, f.Status, code, f.Message)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if segments[0] == "" {
+		writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+		return
+	}
+
+	if len(segments) <= 2 {
+		s.serveWorkspaces(w, r, segments)
+		return
+	}
+	if len(segments) == 3 && segments[1] == "master" && segments[2] == "_promote" {
+		s.servePromote(w, r, s.account(segments[0]))
+		return
+	}
+	if len(segments) == 3 && segments[2] == "_reset" {
+		s.serveReset(w, r, s.account(segments[0]), segments[1])
+		return
+	}
+
+	acc := s.account(segments[0])
+	ws := acc.workspace(segments[1])
+	switch rest := segments[2:]; rest[0] {
+	case "buckets":
+		s.serveBuckets(w, r, ws, rest[1:])
+	case "apps", "dependencies":
+		s.serveApps(w, r, ws, rest)
+	case "registry":
+		s.serveRegistry(w, r, acc, rest[1:])
+	case "events", "logs":
+		s.serveColossus(w, r, segments[0], segments[1], rest)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "No route for "+r.URL.Path)
+	}
+}
+
+func (s *Server) account(name string) *account {
+	acc, ok := s.accounts[name]
+	if !ok {
+		acc = &account{
+			workspaces: map[string]*workspace{"master": newMaster()},
+			registry:   map[string]*publishedApp{},
+		}
+		s.accounts[name] = acc
+	}
+	return acc
+}
+
+// workspace returns a workspace, creating it if needed, as the routes of
+// the services bound to a workspace don't check whether it exists.
+func (acc *account) workspace(name string) *workspace {
+	ws, ok := acc.workspaces[name]
+	if !ok {
+		ws = newWorkspace(false)
+		acc.workspaces[name] = ws
+	}
+	return ws
+}
+
+func newWorkspace(created bool) *workspace {
+	now := time.Now().UTC()
+	return &workspace{
+		created:      created,
+		createdAt:    now,
+		lastModified: now,
+		buckets:      map[string]*bucket{},
+		apps:         map[string]*installedApp{},
+		dependencies: map[string][]string{},
+	}
+}
+
+func newMaster() *workspace {
+	master := newWorkspace(true)
+	master.production = true
+	return master
+}
+
+func writeJSON(w http.ResponseWriter, status int, eTag string, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if eTag != "" {
+		w.Header().Set(clients.HeaderETag, eTag)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, "", clients.ErrorDescriptor{Code: code, Message: message})
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method+" is not allowed on "+r.URL.Path)
+}
+
+// checkPrecondition answers 412 and returns false when the conditional
+// headers of a write don't hold for a resource with the given ETag, empty
+// if it doesn't exist.
+func checkPrecondition(w http.ResponseWriter, r *http.Request, eTag string) bool {
+	if r.Header.Get(clients.HeaderIfNoneMatch) == "*" && eTag != "" {
+		writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "The resource already exists")
+		return false
+	}
+	if ifMatch := r.Header.Get(clients.HeaderIfMatch); ifMatch != "" && ifMatch != eTag {
+		writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "The resource changed")
+		return false
+	}
+	return true
+}
+
+// notModified answers 304 and returns true when a read already has the
+// current ETag of a resource.
+func notModified(w http.ResponseWriter, r *http.Request, eTag string) bool {
+	if eTag != "" && r.Header.Get(clients.HeaderIfNoneMatch) == eTag {
+		w.Header().Set(clients.HeaderETag, eTag)
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+func hash(content []byte) string {
+	return inmem.Hash(content)
+}
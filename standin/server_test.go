@@ -0,0 +1,38 @@
+package standin
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSlowUploadDoesntBlock(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	body, upload := io.Pipe()
+	defer upload.Close()
+	uploaded := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPut, s.URL+"/account/master/buckets/bucket/files/slow.txt", body)
+		res, err := http.DefaultClient.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		uploaded <- err
+	}()
+	upload.Write([]byte("partial "))
+
+	client := &http.Client{Timeout: time.Second}
+	res, err := client.Get(s.URL + "/account/master/buckets/bucket")
+	if err != nil {
+		t.Fatalf("request during a slow upload failed: %v", err)
+	}
+	res.Body.Close()
+
+	upload.Close()
+	if err := <-uploaded; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,169 @@
+package standin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/vtex/go-clients/workspaces"
+)
+
+func (ws *workspace) describe(name string) *workspaces.Workspace {
+	return &workspaces.Workspace{
+		Name:         name,
+		Production:   ws.production,
+		Weight:       ws.weight,
+		CreatedAt:    ws.createdAt,
+		LastModified: ws.lastModified,
+	}
+}
+
+// serveWorkspaces answers the kube-router routes of an account, as in
+// /{account} and /{account}/{workspace}
+func (s *Server) serveWorkspaces(w http.ResponseWriter, r *http.Request, segments []string) {
+	acc := s.account(segments[0])
+
+	if len(segments) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			names := make([]string, 0, len(acc.workspaces))
+			for name, ws := range acc.workspaces {
+				if ws.created {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+
+			list := make([]*workspaces.Workspace, len(names))
+			for i, name := range names {
+				list[i] = acc.workspaces[name].describe(name)
+			}
+			writeJSON(w, http.StatusOK, "", list)
+		case http.MethodPost:
+			var body workspaces.CreateRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+				writeError(w, http.StatusBadRequest, "InvalidName", "A workspace name is required")
+				return
+			}
+			if ws, ok := acc.workspaces[body.Name]; ok && ws.created {
+				writeError(w, http.StatusConflict, "WorkspaceAlreadyExists", "Workspace "+body.Name+" already exists")
+				return
+			}
+			ws := newWorkspace(true)
+			ws.production = body.Production
+			ws.weight = body.Weight
+			acc.workspaces[body.Name] = ws
+			writeJSON(w, http.StatusCreated, "", ws.describe(body.Name))
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	name := segments[1]
+	ws, ok := acc.workspaces[name]
+	if !ok || !ws.created {
+		writeError(w, http.StatusNotFound, "WorkspaceNotFound", "Workspace "+name+" not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, "", ws.describe(name))
+	case http.MethodPatch:
+		var patch workspaces.Patch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidBody", "The body must be a JSON object")
+			return
+		}
+		if patch.Production != nil {
+			ws.production = *patch.Production
+		}
+		if patch.Weight != nil {
+			ws.weight = *patch.Weight
+		}
+		ws.lastModified = time.Now().UTC()
+		writeJSON(w, http.StatusOK, "", ws.describe(name))
+	case http.MethodDelete:
+		if name == "master" {
+			writeError(w, http.StatusBadRequest, "MasterDeletion", "The master workspace can't be deleted")
+			return
+		}
+		delete(acc.workspaces, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+// servePromote answers /{account}/master/_promote. The promoted workspace
+// takes the place of master, with its buckets and apps.
+func (s *Server) servePromote(w http.ResponseWriter, r *http.Request, acc *account) {
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, r)
+		return
+	}
+
+	var body workspaces.PromoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidBody", "The body must name the workspace to promote")
+		return
+	}
+	ws, ok := acc.workspaces[body.Workspace]
+	if !ok || !ws.created {
+		writeError(w, http.StatusNotFound, "WorkspaceNotFound", "Workspace "+body.Workspace+" not found")
+		return
+	}
+	if body.Workspace == "master" {
+		writeError(w, http.StatusBadRequest, "MasterPromotion", "The master workspace can't be promoted")
+		return
+	}
+	if !ws.production {
+		writeError(w, http.StatusBadRequest, "WorkspaceNotProduction", "Only production workspaces can be promoted")
+		return
+	}
+
+	master := acc.workspaces["master"]
+	ws.weight = master.weight
+	ws.createdAt = master.createdAt
+	ws.lastModified = time.Now().UTC()
+	acc.workspaces["master"] = ws
+	delete(acc.workspaces, body.Workspace)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveReset answers /{account}/{workspace}/_reset, replacing the buckets and
+// apps of the workspace with copies of the ones of master.
+func (s *Server) serveReset(w http.ResponseWriter, r *http.Request, acc *account, name string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+
+	ws, ok := acc.workspaces[name]
+	if !ok || !ws.created {
+		writeError(w, http.StatusNotFound, "WorkspaceNotFound", "Workspace "+name+" not found")
+		return
+	}
+	if name == "master" {
+		writeError(w, http.StatusBadRequest, "MasterReset", "The master workspace can't be reset")
+		return
+	}
+
+	master := acc.workspaces["master"]
+	ws.buckets = map[string]*bucket{}
+	for bucketName, b := range master.buckets {
+		ws.buckets[bucketName] = b.clone()
+	}
+	ws.apps = map[string]*installedApp{}
+	for appName, app := range master.apps {
+		ws.apps[appName] = app
+	}
+	ws.dependencies = map[string][]string{}
+	for app, deps := range master.dependencies {
+		ws.dependencies[app] = append([]string(nil), deps...)
+	}
+	ws.lastModified = time.Now().UTC()
+	w.WriteHeader(http.StatusNoContent)
+}
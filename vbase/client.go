@@ -2,8 +2,12 @@ package vbase
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/vtex/go-clients/clients"
 	"gopkg.in/h2non/gentleman.v1"
@@ -22,12 +26,16 @@ type VBase interface {
 	GetBucket(bucket string) (*BucketResponse, string, error)
 	SetBucketState(bucket, state string) (string, error)
 	GetFile(bucket, path string) (*gentleman.Response, string, error)
+	GetFileIfNoneMatch(bucket, path, eTag string) (*gentleman.Response, string, bool, error)
 	GetFileConflict(bucket, path string) (*gentleman.Response, *Conflict, string, error)
 	SaveFile(bucket, path string, body io.Reader) (string, error)
 	SaveFileB(bucket, path string, content []byte, contentType string, unzip bool) (string, error)
+	SaveFileIfMatch(bucket, path string, content []byte, contentType string, unzip bool, eTag string) (string, error)
 	ListFiles(bucket string, options *Options) (*FileListResponse, string, error)
 	ListAllFiles(bucket, prefix string) (*FileListResponse, string, error)
 	DeleteFile(bucket, path string) error
+	DeleteFileIfMatch(bucket, path, eTag string) error
+	WithContext(ctx context.Context) VBase
 }
 
 // Client is a struct that provides interaction with workspaces
@@ -41,6 +49,20 @@ func NewClient(config *clients.Config) VBase {
 	return &Client{cl}
 }
 
+// New creates a VBase client, or returns an error if config is invalid
+func New(config *clients.Config) (VBase, error) {
+	cl, err := clients.NewClient("vbase", config, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{cl}, nil
+}
+
+// WithContext returns a view of the client whose requests are bound to ctx
+func (cl *Client) WithContext(ctx context.Context) VBase {
+	return &Client{clients.WithContext(cl.http, ctx)}
+}
+
 const (
 	pathToBucket      = "/buckets/%v"
 	pathToBucketState = "/buckets/%v/state"
@@ -86,6 +108,23 @@ func (cl *Client) GetFile(bucket, path string) (*gentleman.Response, string, err
 	return res, res.Header.Get(clients.HeaderETag), nil
 }
 
+// GetFileIfNoneMatch gets a file's content only if its ETag is no longer eTag.
+// When it didn't change, no response is returned and modified is false.
+func (cl *Client) GetFileIfNoneMatch(bucket, path, eTag string) (*gentleman.Response, string, bool, error) {
+	res, err := cl.http.Get().
+		AddPath(fmt.Sprintf(pathToFile, bucket, path)).
+		Use(clients.IfNoneMatch(eTag)).Send()
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		return nil, eTag, false, nil
+	}
+
+	return res, res.Header.Get(clients.HeaderETag), true, nil
+}
+
 // GetFileConflict gets a file's content as a byte slice, or conflict
 func (cl *Client) GetFileConflict(bucket, path string) (*gentleman.Response, *Conflict, string, error) {
 	req := cl.http.Get().
@@ -94,12 +133,14 @@ func (cl *Client) GetFileConflict(bucket, path string) (*gentleman.Response, *Co
 
 	res, err := req.Send()
 	if err != nil {
-		if err, ok := err.(clients.ResponseError); ok && err.StatusCode == 409 {
+		// The body of a failed response is only kept in the error
+		var respErr clients.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusConflict {
 			var conflict Conflict
-			if err := res.JSON(&conflict); err != nil {
-				return nil, nil, "", err
+			if err := json.Unmarshal(respErr.Body, &conflict); err != nil {
+				return nil, nil, "", fmt.Errorf("Error unmarshaling conflict: %v", err)
 			}
-			return nil, &conflict, res.Header.Get(clients.HeaderETag), nil
+			return nil, &conflict, respErr.Response.Header.Get(clients.HeaderETag), nil
 		}
 		return nil, nil, "", err
 	}
@@ -130,6 +171,26 @@ func (cl *Client) SaveFileB(bucket, path string, body []byte, contentType string
 	return res.Header.Get(clients.HeaderETag), nil
 }
 
+// SaveFileIfMatch saves a file only if its current ETag is eTag, or only if
+// it doesn't exist when eTag is empty. Otherwise it fails with
+// clients.ErrPreconditionFailed.
+func (cl *Client) SaveFileIfMatch(bucket, path string, body []byte, contentType string, unzip bool, eTag string) (string, error) {
+	req := cl.http.Put().
+		AddPath(fmt.Sprintf(pathToFile, bucket, path)).
+		SetQuery("unzip", fmt.Sprintf("%v", unzip)).
+		Use(clients.IfMatch(eTag))
+	if contentType != "" {
+		req = req.SetHeader("Content-Type", contentType)
+	}
+
+	res, err := req.Body(bytes.NewReader(body)).Send()
+	if err != nil {
+		return "", err
+	}
+
+	return res.Header.Get(clients.HeaderETag), nil
+}
+
 // ListFiles returns a list of files, given a prefix
 func (cl *Client) ListFiles(bucket string, options *Options) (*FileListResponse, string, error) {
 	if options.Limit <= 0 {
@@ -193,3 +254,18 @@ func (cl *Client) DeleteFile(bucket, path string) error {
 
 	return err
 }
+
+// DeleteFileIfMatch deletes a file only if its current ETag is eTag.
+// Otherwise it fails with clients.ErrPreconditionFailed. An empty eTag fails
+// with clients.ErrMissingETag.
+func (cl *Client) DeleteFileIfMatch(bucket, path, eTag string) error {
+	if eTag == "" {
+		return clients.ErrMissingETag
+	}
+
+	_, err := cl.http.Delete().
+		AddPath(fmt.Sprintf(pathToFile, bucket, path)).
+		Use(clients.IfMatch(eTag)).Send()
+
+	return err
+}
@@ -0,0 +1,46 @@
+package vbase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vtex/go-clients/clients"
+)
+
+func TestGetFileConflict(t *testing.T) {
+	// The conflict also carries an error descriptor, which must not hide it
+	const body = `{"code":"Conflict","message":"Merge needed","base":{"content":"a"},"mine":{"content":"b"},"master":{"content":"c"}}`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Conflict-Resolution") != "merge" {
+			t.Errorf("got conflict resolution %q, want merge", r.Header.Get("X-Conflict-Resolution"))
+		}
+		w.Header().Set(clients.HeaderETag, "etag")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	cl, err := New(&clients.Config{
+		Account:        "account",
+		Workspace:      "master",
+		Endpoint:       srv.URL,
+		AuthToken:      "token",
+		RequestContext: clients.NewRequestContext(nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, conflict, eTag, err := cl.GetFileConflict("bucket", "file.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != nil || eTag != "etag" {
+		t.Errorf("got response %v and eTag %q, want no response and the conflict's eTag", res, eTag)
+	}
+	if conflict == nil || conflict.Base.Content != "a" || conflict.Mine.Content != "b" || conflict.Master.Content != "c" {
+		t.Errorf("got conflict %+v, want the one in the body", conflict)
+	}
+}
@@ -0,0 +1,344 @@
+// Package vbasetest checks that implementations of vbase.VBase behave as the
+// client does against the service, so that fakes and alternative backends
+// can't drift from it.
+package vbasetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/vbase"
+	"gopkg.in/h2non/gentleman.v1"
+)
+
+// Factory returns a VBase without files, along with a function that makes
+// GetFileConflict return c for a file. The function may be nil when the
+// backend can't simulate conflicts, in which case conflicts aren't checked.
+type Factory func(t *testing.T) (v vbase.VBase, addConflict func(bucket, path string, c *vbase.Conflict))
+
+const bucket = "conformance"
+
+// RunConformance runs the conformance suite as subtests of t, each on a new
+// VBase from factory.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, v vbase.VBase, addConflict func(bucket, path string, c *vbase.Conflict))
+	}{
+		{"GetMissingFile", testGetMissingFile},
+		{"SaveFile", testSaveFile},
+		{"ETagChangesOnWrite", testETagChangesOnWrite},
+		{"GetFileIfNoneMatch", testGetFileIfNoneMatch},
+		{"SaveFileIfMatch", testSaveFileIfMatch},
+		{"DeleteFile", testDeleteFile},
+		{"DeleteFileIfMatch", testDeleteFileIfMatch},
+		{"ListFiles", testListFiles},
+		{"ListAllFiles", testListAllFiles},
+		{"Bucket", testBucket},
+		{"FileConflict", testFileConflict},
+		{"WithContext", testWithContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			v, addConflict := factory(t)
+			test.run(t, v, addConflict)
+		})
+	}
+}
+
+func testGetMissingFile(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	if _, _, err := v.GetFile(bucket, "missing.txt"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("GetFile of a missing file: got %v, want clients.ErrNotFound", err)
+	}
+	if _, _, _, err := v.GetFileIfNoneMatch(bucket, "missing.txt", "etag"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("GetFileIfNoneMatch of a missing file: got %v, want clients.ErrNotFound", err)
+	}
+}
+
+func testSaveFile(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	eTag, err := v.SaveFileB(bucket, "dir/file.txt", []byte("content"), "text/plain", false)
+	if err != nil {
+		t.Fatalf("SaveFileB: %v", err)
+	}
+	if eTag == "" {
+		t.Error("SaveFileB returned no ETag")
+	}
+	checkFile(t, v, "dir/file.txt", "content", eTag)
+
+	if _, err := v.SaveFile(bucket, "reader.txt", strings.NewReader("from reader")); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	checkFile(t, v, "reader.txt", "from reader", "")
+}
+
+func testETagChangesOnWrite(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	first := saveFile(t, v, "file.txt", "one")
+	second := saveFile(t, v, "file.txt", "two")
+	if first == second {
+		t.Errorf("ETag %q didn't change when the content did", first)
+	}
+	checkFile(t, v, "file.txt", "two", second)
+}
+
+func testGetFileIfNoneMatch(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	eTag := saveFile(t, v, "file.txt", "one")
+
+	res, current, modified, err := v.GetFileIfNoneMatch(bucket, "file.txt", eTag)
+	if err != nil {
+		t.Fatalf("GetFileIfNoneMatch: %v", err)
+	}
+	if modified || res != nil || current != eTag {
+		t.Errorf("GetFileIfNoneMatch of an unchanged file: got modified %v, response %v, ETag %q, want false, nil, %q", modified, res != nil, current, eTag)
+	}
+
+	newETag := saveFile(t, v, "file.txt", "two")
+	res, current, modified, err = v.GetFileIfNoneMatch(bucket, "file.txt", eTag)
+	if err != nil {
+		t.Fatalf("GetFileIfNoneMatch: %v", err)
+	}
+	if !modified || current != newETag {
+		t.Fatalf("GetFileIfNoneMatch of a changed file: got modified %v, ETag %q, want true, %q", modified, current, newETag)
+	}
+	if content := readAll(t, res); content != "two" {
+		t.Errorf("GetFileIfNoneMatch of a changed file: got %q, want %q", content, "two")
+	}
+}
+
+func testSaveFileIfMatch(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	eTag, err := v.SaveFileIfMatch(bucket, "file.txt", []byte("one"), "", false, "")
+	if err != nil {
+		t.Fatalf("SaveFileIfMatch of a new file: %v", err)
+	}
+
+	if _, err := v.SaveFileIfMatch(bucket, "file.txt", []byte("two"), "", false, ""); !errors.Is(err, clients.ErrPreconditionFailed) {
+		t.Errorf("SaveFileIfMatch without ETag of an existing file: got %v, want clients.ErrPreconditionFailed", err)
+	}
+
+	newETag, err := v.SaveFileIfMatch(bucket, "file.txt", []byte("two"), "", false, eTag)
+	if err != nil {
+		t.Fatalf("SaveFileIfMatch with the current ETag: %v", err)
+	}
+
+	if _, err := v.SaveFileIfMatch(bucket, "file.txt", []byte("three"), "", false, eTag); !errors.Is(err, clients.ErrPreconditionFailed) {
+		t.Errorf("SaveFileIfMatch with a stale ETag: got %v, want clients.ErrPreconditionFailed", err)
+	}
+	checkFile(t, v, "file.txt", "two", newETag)
+}
+
+func testDeleteFile(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	saveFile(t, v, "file.txt", "content")
+
+	if err := v.DeleteFile(bucket, "file.txt"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if _, _, err := v.GetFile(bucket, "file.txt"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("GetFile of a deleted file: got %v, want clients.ErrNotFound", err)
+	}
+	if err := v.DeleteFile(bucket, "file.txt"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("DeleteFile of a missing file: got %v, want clients.ErrNotFound", err)
+	}
+}
+
+func testDeleteFileIfMatch(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	stale := saveFile(t, v, "file.txt", "one")
+	eTag := saveFile(t, v, "file.txt", "two")
+
+	if err := v.DeleteFileIfMatch(bucket, "file.txt", stale); !errors.Is(err, clients.ErrPreconditionFailed) {
+		t.Errorf("DeleteFileIfMatch with a stale ETag: got %v, want clients.ErrPreconditionFailed", err)
+	}
+	checkFile(t, v, "file.txt", "two", eTag)
+
+	if err := v.DeleteFileIfMatch(bucket, "file.txt", ""); !errors.Is(err, clients.ErrMissingETag) {
+		t.Errorf("DeleteFileIfMatch without ETag: got %v, want clients.ErrMissingETag", err)
+	}
+	checkFile(t, v, "file.txt", "two", eTag)
+
+	if err := v.DeleteFileIfMatch(bucket, "file.txt", eTag); err != nil {
+		t.Fatalf("DeleteFileIfMatch with the current ETag: %v", err)
+	}
+	if _, _, err := v.GetFile(bucket, "file.txt"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("GetFile of a deleted file: got %v, want clients.ErrNotFound", err)
+	}
+}
+
+func testListFiles(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	var want []string
+	for i := 11; i >= 0; i-- {
+		path := fmt.Sprintf("dir/file%02d.txt", i)
+		saveFile(t, v, path, path)
+		want = append([]string{path}, want...)
+	}
+	saveFile(t, v, "other.txt", "other")
+
+	list, _, err := v.ListFiles(bucket, &vbase.Options{Prefix: "dir/"})
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	if len(list.Files) != 10 || list.NextMarker == "" {
+		t.Errorf("ListFiles without limit: got %d files and marker %q, want 10 files and a marker", len(list.Files), list.NextMarker)
+	}
+
+	var got []string
+	options := &vbase.Options{Prefix: "dir/", Limit: 5}
+	for pages := 1; ; pages++ {
+		list, _, err := v.ListFiles(bucket, options)
+		if err != nil {
+			t.Fatalf("ListFiles: %v", err)
+		}
+		if len(list.Files) > 5 {
+			t.Errorf("ListFiles with limit 5: got %d files", len(list.Files))
+		}
+		for _, f := range list.Files {
+			if f.Hash == "" {
+				t.Errorf("ListFiles: file %s has no hash", f.Path)
+			}
+			got = append(got, f.Path)
+		}
+		if list.NextMarker == "" {
+			break
+		}
+		if pages > len(want) {
+			t.Fatalf("ListFiles: marker %q doesn't progress", list.NextMarker)
+		}
+		options.Marker = list.NextMarker
+	}
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ListFiles pages: got %v, want %v", got, want)
+	}
+}
+
+func testListAllFiles(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	saveFile(t, v, "a/2.txt", "2")
+	saveFile(t, v, "a/1.txt", "1")
+	saveFile(t, v, "b/3.txt", "3")
+
+	list, _, err := v.ListAllFiles(bucket, "a/")
+	if err != nil {
+		t.Fatalf("ListAllFiles: %v", err)
+	}
+	var got []string
+	for _, f := range list.Files {
+		got = append(got, f.Path)
+	}
+	if strings.Join(got, ",") != "a/1.txt,a/2.txt" || list.NextMarker != "" {
+		t.Errorf("ListAllFiles: got %v and marker %q, want [a/1.txt a/2.txt] and no marker", got, list.NextMarker)
+	}
+
+	list, _, err = v.ListAllFiles(bucket, "missing/")
+	if err != nil {
+		t.Fatalf("ListAllFiles: %v", err)
+	}
+	if len(list.Files) != 0 {
+		t.Errorf("ListAllFiles of a missing prefix: got %d files, want none", len(list.Files))
+	}
+}
+
+func testBucket(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	if _, err := v.SetBucketState(bucket, "safe"); err != nil {
+		t.Fatalf("SetBucketState: %v", err)
+	}
+	before, _, err := v.GetBucket(bucket)
+	if err != nil {
+		t.Fatalf("GetBucket: %v", err)
+	}
+	if before.State != "safe" {
+		t.Errorf("GetBucket: got state %q, want %q", before.State, "safe")
+	}
+
+	saveFile(t, v, "file.txt", "content")
+	after, _, err := v.GetBucket(bucket)
+	if err != nil {
+		t.Fatalf("GetBucket: %v", err)
+	}
+	if after.Hash == before.Hash {
+		t.Errorf("GetBucket: hash %q didn't change when a file was saved", after.Hash)
+	}
+}
+
+func testFileConflict(t *testing.T, v vbase.VBase, addConflict func(string, string, *vbase.Conflict)) {
+	if addConflict == nil {
+		t.Skip("the backend can't simulate conflicts")
+	}
+
+	saveFile(t, v, "file.txt", "base")
+	addConflict(bucket, "file.txt", &vbase.Conflict{
+		Base:   &vbase.ConflictEntry{Content: "base"},
+		Mine:   &vbase.ConflictEntry{Content: "mine"},
+		Master: &vbase.ConflictEntry{Content: "master"},
+	})
+
+	res, conflict, _, err := v.GetFileConflict(bucket, "file.txt")
+	if err != nil {
+		t.Fatalf("GetFileConflict: %v", err)
+	}
+	if res != nil || conflict == nil || conflict.Mine == nil || conflict.Mine.Content != "mine" {
+		t.Fatalf("GetFileConflict of a conflicted file: got response %v and conflict %+v, want only the conflict", res != nil, conflict)
+	}
+
+	eTag := saveFile(t, v, "file.txt", "merged")
+	res, conflict, current, err := v.GetFileConflict(bucket, "file.txt")
+	if err != nil {
+		t.Fatalf("GetFileConflict: %v", err)
+	}
+	if conflict != nil || current != eTag {
+		t.Fatalf("GetFileConflict of a saved file: got conflict %+v and ETag %q, want no conflict and %q", conflict, current, eTag)
+	}
+	if content := readAll(t, res); content != "merged" {
+		t.Errorf("GetFileConflict of a saved file: got %q, want %q", content, "merged")
+	}
+}
+
+func testWithContext(t *testing.T, v vbase.VBase, _ func(string, string, *vbase.Conflict)) {
+	saveFile(t, v, "file.txt", "content")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := v.WithContext(ctx).GetFile(bucket, "file.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetFile with a canceled context: got %v, want context.Canceled", err)
+	}
+	checkFile(t, v, "file.txt", "content", "")
+}
+
+func saveFile(t *testing.T, v vbase.VBase, path, content string) string {
+	t.Helper()
+	eTag, err := v.SaveFileB(bucket, path, []byte(content), "text/plain", false)
+	if err != nil {
+		t.Fatalf("SaveFileB %s: %v", path, err)
+	}
+	return eTag
+}
+
+// checkFile checks the content of a file, and its ETag unless eTag is empty
+func checkFile(t *testing.T, v vbase.VBase, path, content, eTag string) {
+	t.Helper()
+	res, current, err := v.GetFile(bucket, path)
+	if err != nil {
+		t.Fatalf("GetFile %s: %v", path, err)
+	}
+	if got := readAll(t, res); got != content {
+		t.Errorf("GetFile %s: got %q, want %q", path, got, content)
+	}
+	if eTag != "" && current != eTag {
+		t.Errorf("GetFile %s: got ETag %q, want %q", path, current, eTag)
+	}
+}
+
+func readAll(t *testing.T, res *gentleman.Response) string {
+	t.Helper()
+	if res == nil {
+		t.Fatal("no response")
+	}
+	content, err := ioutil.ReadAll(res)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	return string(content)
+}
@@ -0,0 +1,31 @@
+package vbasetest
+
+import (
+	"testing"
+
+	"github.com/vtex/go-clients/fakes"
+	"github.com/vtex/go-clients/standin"
+	"github.com/vtex/go-clients/vbase"
+)
+
+func TestFakeConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) (vbase.VBase, func(string, string, *vbase.Conflict)) {
+		v := fakes.NewVBase()
+		return v, v.AddConflict
+	})
+}
+
+func TestClientConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) (vbase.VBase, func(string, string, *vbase.Conflict)) {
+		s := standin.New()
+		t.Cleanup(s.Close)
+
+		v, err := vbase.New(s.Config("account", "workspace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v, func(bucket, path string, c *vbase.Conflict) {
+			s.AddFileConflict("account", "workspace", bucket, path, c)
+		}
+	})
+}
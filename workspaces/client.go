@@ -1,34 +1,68 @@
 package workspaces
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/metadata"
+	"github.com/vtex/go-clients/vbase"
 	"gopkg.in/h2non/gentleman.v1"
 )
 
 type Workspaces interface {
 	List() ([]*Workspace, error)
 	Get(name string) (*Workspace, error)
-	Create(name string) error
+	Create(name string, options *CreateOptions) (*Workspace, error)
 	Delete(name string) error
+	Update(name string, patch *Patch) (*Workspace, error)
+	Promote(name string) error
+	Reset(name string) error
+	Clone(from, to string, options *CloneOptions) error
+	WithContext(ctx context.Context) Workspaces
 }
 
 type Client struct {
 	account string
 	http    *gentleman.Client
+	// config creates the vbase and metadata clients used by Clone
+	config clients.Config
+	ctx    context.Context
 }
 
 func NewClient(config *clients.Config) Workspaces {
 	cl := clients.CreateClient("kube-router", config, false)
-	return &Client{config.Account, cl}
+	return &Client{account: config.Account, http: cl, config: *config}
+}
+
+// New creates a Workspaces client, or returns an error if config is invalid
+func New(config *clients.Config) (Workspaces, error) {
+	cl, err := clients.NewClient("kube-router", config, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{account: config.Account, http: cl, config: *config}, nil
+}
+
+// WithContext returns a view of the client whose requests, including the ones
+// made while cloning, are bound to ctx.
+func (cl *Client) WithContext(ctx context.Context) Workspaces {
+	return &Client{cl.account, clients.WithContext(cl.http, ctx), cl.config, ctx}
 }
 
 const (
 	accountPath   = "/%v"
 	workspacePath = "/%v/%v"
+	promotePath   = "/%v/master/_promote"
+	resetPath     = "/%v/%v/_reset"
 )
 
+// invalidNameCode is the code of the errors the service answers with when it
+// rejects the name of a new workspace
+const invalidNameCode = "InvalidName"
+
 func (cl *Client) List() ([]*Workspace, error) {
 	res, err := cl.http.Get().AddPath(fmt.Sprintf(accountPath, cl.account)).Send()
 	if err != nil {
@@ -57,12 +91,133 @@ func (cl *Client) Get(name string) (*Workspace, error) {
 	return &workspace, nil
 }
 
-func (cl *Client) Create(name string) error {
-	_, err := cl.http.Post().AddPath(fmt.Sprintf(accountPath, cl.account)).Send()
-	return err
+// Create creates a workspace, with the default settings when options is nil.
+// A taken or invalid name fails with a *CreateError.
+func (cl *Client) Create(name string, options *CreateOptions) (*Workspace, error) {
+	if name == "" {
+		return nil, &CreateError{Name: name, Err: ErrInvalidName}
+	}
+	if options == nil {
+		options = &CreateOptions{}
+	}
+
+	res, err := cl.http.Post().
+		AddPath(fmt.Sprintf(accountPath, cl.account)).
+		JSON(&CreateRequest{Name: name, Production: options.Production, Weight: options.Weight}).
+		Send()
+	if err != nil {
+		var respErr clients.ResponseError
+		if errors.As(err, &respErr) {
+			switch respErr.StatusCode {
+			case http.StatusConflict:
+				return nil, &CreateError{Name: name, Err: ErrWorkspaceExists, Cause: err}
+			case http.StatusBadRequest:
+				if respErr.Code == invalidNameCode {
+					return nil, &CreateError{Name: name, Err: ErrInvalidName, Cause: err}
+				}
+			}
+		}
+		return nil, err
+	}
+
+	// The service may answer without describing the workspace.
+	workspace := Workspace{Name: name}
+	if err := res.JSON(&workspace); err != nil {
+		return nil, err
+	}
+
+	return &workspace, nil
 }
 
 func (cl *Client) Delete(name string) error {
 	_, err := cl.http.Delete().AddPath(fmt.Sprintf(workspacePath, cl.account, name)).Send()
 	return err
 }
+
+// Update changes the production flag or the weight of a workspace, leaving
+// the fields that patch doesn't set as they are.
+func (cl *Client) Update(name string, patch *Patch) (*Workspace, error) {
+	if patch == nil {
+		patch = &Patch{}
+	}
+
+	res, err := cl.http.Patch().
+		AddPath(fmt.Sprintf(workspacePath, cl.account, name)).
+		JSON(patch).
+		Send()
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := Workspace{Name: name}
+	if err := res.JSON(&workspace); err != nil {
+		return nil, err
+	}
+
+	return &workspace, nil
+}
+
+// Promote makes a production workspace the master workspace of the account
+func (cl *Client) Promote(name string) error {
+	_, err := cl.http.Put().
+		AddPath(fmt.Sprintf(promotePath, cl.account)).
+		JSON(&PromoteRequest{Workspace: name}).
+		Send()
+	return err
+}
+
+// Reset brings a workspace back to the state of master
+func (cl *Client) Reset(name string) error {
+	_, err := cl.http.Post().
+		AddPath(fmt.Sprintf(resetPath, cl.account, name)).
+		Send()
+	return err
+}
+
+// Clone copies the buckets listed in options from a workspace to another,
+// creating it if needed. Buckets are made identical, so files and keys that
+// only the target has are deleted.
+func (cl *Client) Clone(from, to string, options *CloneOptions) error {
+	if err := cl.clone(from, to, options); err != nil {
+		return fmt.Errorf("Error cloning workspace %s to %s: %v", from, to, err)
+	}
+	return nil
+}
+
+func (cl *Client) clone(from, to string, options *CloneOptions) error {
+	if options == nil {
+		options = &CloneOptions{}
+	}
+	if err := PrepareClone(cl, from, to, options.Create); err != nil {
+		return err
+	}
+
+	src, err := cl.storage(from)
+	if err != nil {
+		return err
+	}
+	dst, err := cl.storage(to)
+	if err != nil {
+		return err
+	}
+	return CopyBuckets(src, dst, options)
+}
+
+func (cl *Client) storage(workspace string) (*Storage, error) {
+	config := cl.config
+	config.Workspace = workspace
+
+	vb, err := vbase.New(&config)
+	if err != nil {
+		return nil, err
+	}
+	md, err := metadata.New(&config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if cl.ctx != nil {
+		vb, md = vb.WithContext(cl.ctx), md.WithContext(cl.ctx)
+	}
+	return &Storage{vb, md}, nil
+}
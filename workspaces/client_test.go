@@ -0,0 +1,39 @@
+package workspaces
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vtex/go-clients/clients"
+)
+
+func TestCreateBadRequest(t *testing.T) {
+	for code, invalidName := range map[string]bool{"InvalidName": true, "InvalidWeight": false} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"` + code + `","message":"rejected"}`))
+		}))
+
+		cl, err := New(&clients.Config{
+			Account:        "account",
+			Endpoint:       srv.URL,
+			AuthToken:      "token",
+			RequestContext: clients.NewRequestContext(nil),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = cl.Create("workspace", nil)
+		var respErr clients.ResponseError
+		if !errors.As(err, &respErr) || respErr.Code != code {
+			t.Errorf("%s: got error %v, want the response error", code, err)
+		}
+		if errors.Is(err, ErrInvalidName) != invalidName {
+			t.Errorf("%s: errors.Is(%v, ErrInvalidName) = %v, want %v", code, err, !invalidName, invalidName)
+		}
+		srv.Close()
+	}
+}
@@ -0,0 +1,181 @@
+package workspaces
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/metadata"
+	"github.com/vtex/go-clients/vbase"
+)
+
+// metadataBatchSize is how many keys are saved per request when copying a
+// metadata bucket
+const metadataBatchSize = 100
+
+// CloneOptions lists what Clone copies, as the services can't list the
+// buckets of a workspace.
+type CloneOptions struct {
+	VBaseBuckets    []string
+	MetadataBuckets []string
+	// Create is used to create the target workspace when it doesn't exist
+	Create *CreateOptions
+	// Progress, if set, is called as the files and keys of each bucket are
+	// copied
+	Progress func(CloneProgress)
+}
+
+// CloneProgress reports how much of a bucket was copied
+type CloneProgress struct {
+	// Service is "vbase" or "metadata"
+	Service string
+	Bucket  string
+	// Done is how many files or keys of the bucket were copied so far
+	Done  int
+	Total int
+}
+
+// Storage holds the clients of the buckets of a workspace
+type Storage struct {
+	VBase    vbase.VBase
+	Metadata metadata.Metadata
+}
+
+// PrepareClone checks that workspace from can be cloned into to, creating
+// to with the create options when it doesn't exist. Implementations of
+// Workspaces call it before CopyBuckets.
+func PrepareClone(w Workspaces, from, to string, create *CreateOptions) error {
+	if from == to {
+		return errors.New("can't clone a workspace into itself")
+	}
+	if to == "master" {
+		return errors.New("can't clone into master")
+	}
+	if _, err := w.Get(from); err != nil {
+		return err
+	}
+
+	if _, err := w.Get(to); errors.Is(err, clients.ErrNotFound) {
+		_, err = w.Create(to, create)
+		return err
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CopyBuckets makes the buckets listed in options identical in dst and src:
+// files and keys are copied, and the ones src doesn't have are deleted.
+func CopyBuckets(src, dst *Storage, options *CloneOptions) error {
+	for _, bucket := range options.VBaseBuckets {
+		if err := copyVBaseBucket(src.VBase, dst.VBase, bucket, options.Progress); err != nil {
+			return fmt.Errorf("Error copying vbase bucket %s: %v", bucket, err)
+		}
+	}
+	for _, bucket := range options.MetadataBuckets {
+		if err := copyMetadataBucket(src.Metadata, dst.Metadata, bucket, options.Progress); err != nil {
+			return fmt.Errorf("Error copying metadata bucket %s: %v", bucket, err)
+		}
+	}
+	return nil
+}
+
+func copyVBaseBucket(src, dst vbase.VBase, bucket string, progress func(CloneProgress)) error {
+	state, _, err := src.GetBucket(bucket)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.SetBucketState(bucket, state.State); err != nil {
+		return err
+	}
+
+	files, _, err := src.ListAllFiles(bucket, "")
+	if err != nil {
+		return err
+	}
+	report(progress, CloneProgress{"vbase", bucket, 0, len(files.Files)})
+
+	copied := map[string]bool{}
+	for i, f := range files.Files {
+		if err := copyFile(src, dst, bucket, f.Path); err != nil {
+			return err
+		}
+		copied[f.Path] = true
+		report(progress, CloneProgress{"vbase", bucket, i + 1, len(files.Files)})
+	}
+
+	existing, _, err := dst.ListAllFiles(bucket, "")
+	if err != nil {
+		return err
+	}
+	for _, f := range existing.Files {
+		if !copied[f.Path] {
+			if err := dst.DeleteFile(bucket, f.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst vbase.VBase, bucket, path string) error {
+	res, _, err := src.GetFile(bucket, path)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	content, err := ioutil.ReadAll(res)
+	if err != nil {
+		return err
+	}
+	_, err = dst.SaveFileB(bucket, path, content, res.Header.Get("Content-Type"), false)
+	return err
+}
+
+func copyMetadataBucket(src, dst metadata.Metadata, bucket string, progress func(CloneProgress)) error {
+	list, _, err := src.ListAll(bucket, true)
+	if err != nil {
+		return err
+	}
+	report(progress, CloneProgress{"metadata", bucket, 0, len(list.Data)})
+
+	copied := map[string]bool{}
+	for start := 0; start < len(list.Data); start += metadataBatchSize {
+		end := start + metadataBatchSize
+		if end > len(list.Data) {
+			end = len(list.Data)
+		}
+
+		batch := make(map[string]interface{}, end-start)
+		for _, entry := range list.Data[start:end] {
+			batch[entry.Key] = json.RawMessage(entry.Value)
+			copied[entry.Key] = true
+		}
+		if _, err := dst.SaveAll(bucket, batch); err != nil {
+			return err
+		}
+		report(progress, CloneProgress{"metadata", bucket, end, len(list.Data)})
+	}
+
+	existing, _, err := dst.ListAll(bucket, false)
+	if err != nil {
+		return err
+	}
+	for _, entry := range existing.Data {
+		if !copied[entry.Key] {
+			if _, err := dst.Delete(bucket, entry.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func report(progress func(CloneProgress), p CloneProgress) {
+	if progress != nil {
+		progress(p)
+	}
+}
@@ -0,0 +1,110 @@
+package workspaces_test
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/vtex/go-clients/fakes"
+	"github.com/vtex/go-clients/vbase"
+	"github.com/vtex/go-clients/workspaces"
+	"gopkg.in/h2non/gentleman.v1"
+)
+
+// closeCounter is a VBase that counts the files it serves that are still open
+type closeCounter struct {
+	vbase.VBase
+	open int
+}
+
+type countedBody struct {
+	io.ReadCloser
+	open *int
+}
+
+func (b *countedBody) Close() error {
+	*b.open--
+	return b.ReadCloser.Close()
+}
+
+func (v *closeCounter) GetFile(bucket, path string) (*gentleman.Response, string, error) {
+	res, eTag, err := v.VBase.GetFile(bucket, path)
+	if err == nil {
+		v.open++
+		res.RawResponse.Body = &countedBody{res.RawResponse.Body, &v.open}
+	}
+	return res, eTag, err
+}
+
+func TestCopyBuckets(t *testing.T) {
+	w := fakes.NewWorkspaces()
+	srcVBase := &closeCounter{VBase: w.VBase("master")}
+	src := &workspaces.Storage{VBase: srcVBase, Metadata: w.Metadata("master", nil)}
+	dst := &workspaces.Storage{VBase: w.VBase("dev"), Metadata: w.Metadata("dev", nil)}
+
+	src.VBase.SaveFileB("files", "a.json", []byte(`{"a":1}`), "application/json", false)
+	src.VBase.SaveFileB("files", "b.txt", []byte("b"), "text/plain", false)
+	src.Metadata.Save("keys", "x", map[string]int{"x": 1})
+	dst.VBase.SaveFileB("files", "a.json", []byte("old"), "text/plain", false)
+	dst.VBase.SaveFileB("files", "stale.txt", []byte("stale"), "text/plain", false)
+	dst.Metadata.Save("keys", "stale", map[string]int{"stale": 1})
+
+	err := workspaces.CopyBuckets(src, dst, &workspaces.CloneOptions{
+		VBaseBuckets:    []string{"files"},
+		MetadataBuckets: []string{"keys"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _, err := dst.VBase.ListAllFiles("files", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files.Files) != 2 {
+		t.Errorf("got %d files, want the 2 of the source without the stale one", len(files.Files))
+	}
+	res, _, err := dst.VBase.GetFile("files", "a.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadAll(res); string(content) != `{"a":1}` || res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("got %q of type %q, want the source's file", content, res.Header.Get("Content-Type"))
+	}
+	if _, _, err := dst.VBase.GetFile("files", "stale.txt"); err == nil {
+		t.Error("file the source lacks was kept")
+	}
+
+	keys, _, err := dst.Metadata.ListAll("keys", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.Data) != 1 || keys.Data[0].Key != "x" {
+		t.Errorf("got keys %+v, want only the source's x", keys.Data)
+	}
+
+	if srcVBase.open != 0 {
+		t.Errorf("%d files of the source were left open", srcVBase.open)
+	}
+}
+
+func TestCloneFakes(t *testing.T) {
+	w := fakes.NewWorkspaces()
+	w.VBase("master").SaveFileB("files", "a.txt", []byte("a"), "text/plain", false)
+	if _, err := w.Create("dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	w.VBase("dev").SaveFileB("files", "stale.txt", []byte("stale"), "text/plain", false)
+
+	if err := w.Clone("master", "dev", &workspaces.CloneOptions{VBaseBuckets: []string{"files"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _, err := w.VBase("dev").ListAllFiles("files", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files.Files) != 1 || files.Files[0].Path != "a.txt" {
+		t.Errorf("got files %+v, want only a.txt", files.Files)
+	}
+}
@@ -0,0 +1,38 @@
+package workspaces
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors matched by CreateError, for use with errors.Is
+var (
+	ErrWorkspaceExists = errors.New("workspace already exists")
+	ErrInvalidName     = errors.New("invalid workspace name")
+)
+
+// CreateError is returned when a workspace can't be created with a name. It
+// matches its Err with errors.Is and unwraps to the Cause.
+type CreateError struct {
+	Name string
+	// Err is ErrWorkspaceExists or ErrInvalidName
+	Err error
+	// Cause is the response error of the service, or nil when the name was
+	// rejected without sending a request
+	Cause error
+}
+
+func (err *CreateError) Error() string {
+	if err.Cause == nil {
+		return fmt.Sprintf("Error creating workspace %q: %v", err.Name, err.Err)
+	}
+	return fmt.Sprintf("Error creating workspace %q: %v: %v", err.Name, err.Err, err.Cause)
+}
+
+func (err *CreateError) Is(target error) bool {
+	return target == err.Err
+}
+
+func (err *CreateError) Unwrap() error {
+	return err.Cause
+}
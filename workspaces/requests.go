@@ -0,0 +1,27 @@
+package workspaces
+
+// CreateOptions are the optional settings of a new workspace
+type CreateOptions struct {
+	// Production workspaces are cached and indexed as master is
+	Production bool
+	// Weight is the share of traffic routed to the workspace in A/B tests
+	Weight int
+}
+
+// CreateRequest is the body sent to create a workspace
+type CreateRequest struct {
+	Name       string `json:"name"`
+	Production bool   `json:"production"`
+	Weight     int    `json:"weight,omitempty"`
+}
+
+// Patch changes the settings of a workspace. Nil fields are left as they are.
+type Patch struct {
+	Production *bool `json:"production,omitempty"`
+	Weight     *int  `json:"weight,omitempty"`
+}
+
+// PromoteRequest is the body sent to promote a workspace to master
+type PromoteRequest struct {
+	Workspace string `json:"workspace"`
+}
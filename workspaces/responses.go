@@ -1,5 +1,14 @@
 package workspaces
 
+import "time"
+
+// Workspace describes a workspace of an account
 type Workspace struct {
-	Name string
+	Name string `json:"name"`
+	// Production workspaces are cached and indexed as master is
+	Production bool `json:"production"`
+	// Weight is the share of traffic routed to the workspace in A/B tests
+	Weight       int       `json:"weight"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastModified time.Time `json:"lastModified"`
 }
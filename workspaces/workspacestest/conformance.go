@@ -0,0 +1,402 @@
+// Package workspacestest checks that implementations of
+// workspaces.Workspaces behave as the client does against the service, so
+// that fakes and alternative backends can't drift from it.
+package workspacestest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/vtex/go-clients/clients"
+	"github.com/vtex/go-clients/workspaces"
+)
+
+// Factory returns a Workspaces of an account that only has its master
+// workspace, along with a function returning the buckets of a workspace of
+// the account. The function may be nil when the backend has no buckets, in
+// which case what Reset and Clone copy isn't checked.
+type Factory func(t *testing.T) (w workspaces.Workspaces, storage func(workspace string) *workspaces.Storage)
+
+// RunConformance runs the conformance suite as subtests of t, each on a new
+// Workspaces from factory.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, w workspaces.Workspaces, storage func(string) *workspaces.Storage)
+	}{
+		{"Master", testMaster},
+		{"GetMissing", testGetMissing},
+		{"Create", testCreate},
+		{"CreateInvalid", testCreateInvalid},
+		{"CreateExisting", testCreateExisting},
+		{"Delete", testDelete},
+		{"DeleteMaster", testDeleteMaster},
+		{"Update", testUpdate},
+		{"Promote", testPromote},
+		{"Reset", testReset},
+		{"Clone", testClone},
+		{"WithContext", testWithContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			w, storage := factory(t)
+			test.run(t, w, storage)
+		})
+	}
+}
+
+func testMaster(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	master, err := w.Get("master")
+	if err != nil {
+		t.Fatalf("Get master: %v", err)
+	}
+	if master.Name != "master" || !master.Production {
+		t.Errorf("Get master: got %+v, want a production workspace named master", master)
+	}
+	if master.CreatedAt.IsZero() {
+		t.Error("Get master: no creation time")
+	}
+	checkList(t, w, "master")
+}
+
+func testGetMissing(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	if _, err := w.Get("missing"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("Get of a missing workspace: got %v, want clients.ErrNotFound", err)
+	}
+}
+
+func testCreate(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	created, err := w.Create("dev", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.Name != "dev" {
+		t.Errorf("Create: got %q, want %q", created.Name, "dev")
+	}
+
+	dev, err := w.Get("dev")
+	if err != nil {
+		t.Fatalf("Get of a created workspace: %v", err)
+	}
+	if dev.Name != "dev" {
+		t.Errorf("Get of a created workspace: got %q, want %q", dev.Name, "dev")
+	}
+	checkList(t, w, "dev", "master")
+
+	prod, err := w.Create("prod", &workspaces.CreateOptions{Production: true, Weight: 10})
+	if err != nil {
+		t.Fatalf("Create with options: %v", err)
+	}
+	if !prod.Production || prod.Weight != 10 {
+		t.Errorf("Create with options: got %+v, want a production workspace with weight 10", prod)
+	}
+	checkWorkspace(t, w, "prod", true, 10)
+	checkWorkspace(t, w, "dev", false, 0)
+	checkList(t, w, "dev", "master", "prod")
+}
+
+func testCreateInvalid(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	if _, err := w.Create("", nil); !errors.Is(err, workspaces.ErrInvalidName) {
+		t.Errorf("Create without name: got %v, want workspaces.ErrInvalidName", err)
+	}
+	checkList(t, w, "master")
+}
+
+func testCreateExisting(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	if _, err := w.Create("dev", nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	_, err := w.Create("dev", nil)
+	if !errors.Is(err, workspaces.ErrWorkspaceExists) {
+		t.Errorf("Create of an existing workspace: got %v, want workspaces.ErrWorkspaceExists", err)
+	}
+	var createErr *workspaces.CreateError
+	if !errors.As(err, &createErr) || createErr.Name != "dev" {
+		t.Errorf("Create of an existing workspace: got %v, want a *workspaces.CreateError for dev", err)
+	}
+	if !errors.Is(err, clients.ErrConflict) {
+		t.Errorf("Create of an existing workspace: got %v, want clients.ErrConflict", err)
+	}
+	checkList(t, w, "dev", "master")
+}
+
+func testDelete(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	if _, err := w.Create("dev", nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := w.Delete("dev"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := w.Get("dev"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("Get of a deleted workspace: got %v, want clients.ErrNotFound", err)
+	}
+	if err := w.Delete("dev"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("Delete of a missing workspace: got %v, want clients.ErrNotFound", err)
+	}
+	checkList(t, w, "master")
+}
+
+func testUpdate(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	created, err := w.Create("dev", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	weight := 30
+	updated, err := w.Update("dev", &workspaces.Patch{Weight: &weight})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Name != "dev" || updated.Weight != 30 || updated.Production {
+		t.Errorf("Update of the weight: got %+v, want dev with weight 30 and no production flag", updated)
+	}
+	if updated.LastModified.Before(created.LastModified) {
+		t.Errorf("Update: last modified %v is before the creation at %v", updated.LastModified, created.LastModified)
+	}
+	checkWorkspace(t, w, "dev", false, 30)
+
+	production := true
+	if _, err := w.Update("dev", &workspaces.Patch{Production: &production}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	checkWorkspace(t, w, "dev", true, 30)
+
+	if _, err := w.Update("missing", &workspaces.Patch{Weight: &weight}); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("Update of a missing workspace: got %v, want clients.ErrNotFound", err)
+	}
+}
+
+func testPromote(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	if _, err := w.Create("dev", nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := w.Promote("dev"); err == nil {
+		t.Error("Promote of a development workspace succeeded")
+	}
+	if err := w.Promote("master"); err == nil {
+		t.Error("Promote of master succeeded")
+	}
+	if err := w.Promote("missing"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("Promote of a missing workspace: got %v, want clients.ErrNotFound", err)
+	}
+
+	if _, err := w.Create("prod", &workspaces.CreateOptions{Production: true}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := w.Promote("prod"); err != nil {
+		t.Fatalf("Promote of a production workspace: %v", err)
+	}
+	checkWorkspace(t, w, "master", true, 0)
+}
+
+func testDeleteMaster(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	if err := w.Delete("master"); err == nil {
+		t.Error("Delete of master succeeded")
+	}
+	checkList(t, w, "master")
+}
+
+func testWithContext(t *testing.T, w workspaces.Workspaces, _ func(string) *workspaces.Storage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := w.WithContext(ctx).List(); !errors.Is(err, context.Canceled) {
+		t.Errorf("List with a canceled context: got %v, want context.Canceled", err)
+	}
+}
+
+func testReset(t *testing.T, w workspaces.Workspaces, storage func(string) *workspaces.Storage) {
+	if err := w.Reset("master"); err == nil {
+		t.Error("Reset of master succeeded")
+	}
+	if err := w.Reset("missing"); !errors.Is(err, clients.ErrNotFound) {
+		t.Errorf("Reset of a missing workspace: got %v, want clients.ErrNotFound", err)
+	}
+	if _, err := w.Create("dev", nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if storage == nil {
+		if err := w.Reset("dev"); err != nil {
+			t.Fatalf("Reset: %v", err)
+		}
+		return
+	}
+
+	master, dev := storage("master"), storage("dev")
+	saveFile(t, master, "files", "shared.txt", "master")
+	saveKey(t, master, "keys", "shared", "master")
+	saveFile(t, dev, "files", "shared.txt", "dev")
+	saveFile(t, dev, "files", "dev.txt", "dev")
+	saveKey(t, dev, "keys", "dev", "dev")
+
+	if err := w.Reset("dev"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	checkFiles(t, dev, "files", map[string]string{"shared.txt": "master"})
+	checkKeys(t, dev, "keys", map[string]string{"shared": "master"})
+	checkFiles(t, master, "files", map[string]string{"shared.txt": "master"})
+}
+
+func testClone(t *testing.T, w workspaces.Workspaces, storage func(string) *workspaces.Storage) {
+	if err := w.Clone("master", "master", nil); err == nil {
+		t.Error("Clone of master into itself succeeded")
+	}
+	if err := w.Clone("missing", "qa", nil); err == nil {
+		t.Error("Clone of a missing workspace succeeded")
+	}
+	if storage == nil {
+		t.Skip("the backend has no buckets")
+	}
+
+	master := storage("master")
+	saveFile(t, master, "files", "a.txt", "a")
+	saveFile(t, master, "files", "dir/b.txt", "b")
+	saveFile(t, master, "other", "c.txt", "c")
+	saveKey(t, master, "keys", "x", "x")
+	saveKey(t, master, "keys", "y", "y")
+
+	last := map[string]workspaces.CloneProgress{}
+	options := &workspaces.CloneOptions{
+		VBaseBuckets:    []string{"files"},
+		MetadataBuckets: []string{"keys"},
+		Create:          &workspaces.CreateOptions{Weight: 5},
+		Progress: func(p workspaces.CloneProgress) {
+			if p.Done > p.Total {
+				t.Errorf("Clone: progress of %s bucket %s at %d of %d", p.Service, p.Bucket, p.Done, p.Total)
+			}
+			last[p.Service+"/"+p.Bucket] = p
+		},
+	}
+	if err := w.Clone("master", "qa", options); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	checkWorkspace(t, w, "qa", false, 5)
+
+	qa := storage("qa")
+	checkFiles(t, qa, "files", map[string]string{"a.txt": "a", "dir/b.txt": "b"})
+	checkFiles(t, qa, "other", map[string]string{})
+	checkKeys(t, qa, "keys", map[string]string{"x": "x", "y": "y"})
+	for _, bucket := range []string{"vbase/files", "metadata/keys"} {
+		if p, ok := last[bucket]; !ok || p.Done != 2 || p.Total != 2 {
+			t.Errorf("Clone: got last progress %+v for %s, want 2 of 2", p, bucket)
+		}
+	}
+
+	// Cloning into an existing workspace makes its buckets identical.
+	saveFile(t, master, "files", "a.txt", "changed")
+	saveFile(t, qa, "files", "extra.txt", "extra")
+	saveKey(t, qa, "keys", "extra", "extra")
+	if err := w.Clone("master", "qa", options); err != nil {
+		t.Fatalf("Clone into an existing workspace: %v", err)
+	}
+	checkFiles(t, qa, "files", map[string]string{"a.txt": "changed", "dir/b.txt": "b"})
+	checkKeys(t, qa, "keys", map[string]string{"x": "x", "y": "y"})
+
+	if err := w.Clone("qa", "master", options); err == nil {
+		t.Error("Clone into master succeeded")
+	}
+}
+
+func saveFile(t *testing.T, s *workspaces.Storage, bucket, path, content string) {
+	t.Helper()
+	if _, err := s.VBase.SaveFileB(bucket, path, []byte(content), "text/plain", false); err != nil {
+		t.Fatalf("SaveFileB %s: %v", path, err)
+	}
+}
+
+type keyValue struct {
+	Value string `json:"value"`
+}
+
+func saveKey(t *testing.T, s *workspaces.Storage, bucket, key, value string) {
+	t.Helper()
+	if _, err := s.Metadata.Save(bucket, key, &keyValue{value}); err != nil {
+		t.Fatalf("Save %s: %v", key, err)
+	}
+}
+
+// checkFiles checks that a vbase bucket holds exactly the files given
+func checkFiles(t *testing.T, s *workspaces.Storage, bucket string, want map[string]string) {
+	t.Helper()
+	list, _, err := s.VBase.ListAllFiles(bucket, "")
+	if err != nil {
+		t.Fatalf("ListAllFiles %s: %v", bucket, err)
+	}
+	if len(list.Files) != len(want) {
+		t.Errorf("bucket %s: got %d files, want %d", bucket, len(list.Files), len(want))
+	}
+	for path, content := range want {
+		res, _, err := s.VBase.GetFile(bucket, path)
+		if err != nil {
+			t.Errorf("GetFile %s: %v", path, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(res)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+		if string(got) != content {
+			t.Errorf("GetFile %s: got %q, want %q", path, got, content)
+		}
+	}
+}
+
+// checkKeys checks that a metadata bucket holds exactly the keys given
+func checkKeys(t *testing.T, s *workspaces.Storage, bucket string, want map[string]string) {
+	t.Helper()
+	list, _, err := s.Metadata.ListAll(bucket, false)
+	if err != nil {
+		t.Fatalf("ListAll %s: %v", bucket, err)
+	}
+	if len(list.Data) != len(want) {
+		t.Errorf("bucket %s: got %d keys, want %d", bucket, len(list.Data), len(want))
+	}
+	for key, value := range want {
+		var got keyValue
+		if _, err := s.Metadata.Get(bucket, key, &got); err != nil {
+			t.Errorf("Get %s: %v", key, err)
+			continue
+		}
+		if got.Value != value {
+			t.Errorf("Get %s: got %q, want %q", key, got.Value, value)
+		}
+	}
+}
+
+func checkWorkspace(t *testing.T, w workspaces.Workspaces, name string, production bool, weight int) {
+	t.Helper()
+	workspace, err := w.Get(name)
+	if err != nil {
+		t.Fatalf("Get %s: %v", name, err)
+	}
+	if workspace.Name != name || workspace.Production != production || workspace.Weight != weight {
+		t.Errorf("Get %s: got %+v, want production %v and weight %d", name, workspace, production, weight)
+	}
+}
+
+// checkList checks the names of the workspaces, in any order
+func checkList(t *testing.T, w workspaces.Workspaces, names ...string) {
+	t.Helper()
+	list, err := w.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	want := map[string]bool{}
+	for _, name := range names {
+		want[name] = true
+	}
+	for _, workspace := range list {
+		if !want[workspace.Name] {
+			t.Errorf("List: got unexpected workspace %q", workspace.Name)
+		}
+		delete(want, workspace.Name)
+	}
+	for name := range want {
+		t.Errorf("List: missing workspace %q", name)
+	}
+}
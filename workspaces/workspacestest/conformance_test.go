@@ -0,0 +1,43 @@
+package workspacestest
+
+import (
+	"testing"
+
+	"github.com/vtex/go-clients/fakes"
+	"github.com/vtex/go-clients/metadata"
+	"github.com/vtex/go-clients/standin"
+	"github.com/vtex/go-clients/vbase"
+	"github.com/vtex/go-clients/workspaces"
+)
+
+func TestFakeConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) (workspaces.Workspaces, func(string) *workspaces.Storage) {
+		w := fakes.NewWorkspaces()
+		return w, func(workspace string) *workspaces.Storage {
+			return &workspaces.Storage{VBase: w.VBase(workspace), Metadata: w.Metadata(workspace, nil)}
+		}
+	})
+}
+
+func TestClientConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) (workspaces.Workspaces, func(string) *workspaces.Storage) {
+		s := standin.New()
+		t.Cleanup(s.Close)
+
+		w, err := workspaces.New(s.Config("account", "master"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w, func(workspace string) *workspaces.Storage {
+			v, err := vbase.New(s.Config("account", workspace))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := metadata.New(s.Config("account", workspace), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return &workspaces.Storage{VBase: v, Metadata: m}
+		}
+	})
+}